@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/client"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrNoTrustedCA = errors.New("trusted CA public key is not configured")
+var ErrUntrustedKRL = errors.New("KRL is not signed by trusted CA")
+var ErrOlderKRL = errors.New("KRL is older than the installed one")
+
+// KRLAgent keeps sshd's RevokedKeys file in sync with the CA
+type KRLAgent struct {
+	client      *client.Client
+	cfg         *config.KRLAgentConfig
+	trustedCA   ssh.PublicKey
+	tasks       *utils.ScheduledTaskGroup
+	etag        string
+	version     uint64
+	lastUpdated time.Time
+	alerted     bool
+	lock        *sync.Mutex
+}
+
+func NewKRLAgent(cfg *config.KRLAgentConfig) (*KRLAgent, error) {
+	if cfg.CAPublicKey == "" {
+		return nil, ErrNoTrustedCA
+	}
+
+	trustedCA, err := utils.ParseSSHPublicKey([]byte(cfg.CAPublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(cfg.Server, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Role == "" {
+		cfg.Role = "user"
+	}
+
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 60
+	}
+
+	ka := &KRLAgent{
+		client:      c,
+		cfg:         cfg,
+		trustedCA:   trustedCA,
+		tasks:       utils.NewScheduledTaskGroup("krl"),
+		lastUpdated: time.Now(),
+		lock:        &sync.Mutex{},
+	}
+
+	// never go backward from the installed KRL
+	if installed, err := os.ReadFile(cfg.RevokedKeysPath); err == nil {
+		k, err := ka.verify(installed)
+		if err != nil {
+			log.Printf("ignore installed KRL %s: %v", cfg.RevokedKeysPath, err)
+		} else {
+			ka.version = k.Version
+		}
+	}
+
+	return ka, nil
+}
+
+func (ka *KRLAgent) Start() {
+	err := ka.poll()
+	if err != nil {
+		log.Printf("update KRL failed: %v", err)
+	}
+
+	interval := time.Duration(ka.cfg.PollInterval) * time.Second
+
+	ka.tasks.AddPerodical(interval, func() error {
+		err := ka.poll()
+		if err != nil {
+			log.Printf("update KRL failed: %v", err)
+		}
+
+		ka.checkStale()
+
+		return nil
+	})
+}
+
+func (ka *KRLAgent) Stop() {
+	ka.tasks.WaitAndStop()
+}
+
+// parse KRL and make sure it's signed by the trusted CA
+func (ka *KRLAgent) verify(data []byte) (*krl.KRL, error) {
+	k, err := krl.ParseKRL(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, signer := range k.SigningKeys {
+		if bytes.Equal(signer.Marshal(), ka.trustedCA.Marshal()) {
+			return k, nil
+		}
+	}
+
+	return nil, ErrUntrustedKRL
+}
+
+// fetch KRL if it's changed and install it
+func (ka *KRLAgent) poll() error {
+	ka.lock.Lock()
+	defer ka.lock.Unlock()
+
+	data, etag, changed, err := ka.client.GetRevokedIfChanged(ka.cfg.Role, ka.etag)
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		ka.updated()
+		return nil
+	}
+
+	k, err := ka.verify(data)
+	if err != nil {
+		return err
+	}
+
+	if k.Version < ka.version {
+		return fmt.Errorf("%w: got version %d, installed %d", ErrOlderKRL, k.Version, ka.version)
+	}
+
+	if k.Version > ka.version || !utils.IsFileExist(ka.cfg.RevokedKeysPath) {
+		err = utils.WriteFileAtomic(ka.cfg.RevokedKeysPath, data, 0644)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("installed KRL version %d to %s", k.Version, ka.cfg.RevokedKeysPath)
+	}
+
+	ka.version = k.Version
+	ka.etag = etag
+	ka.updated()
+
+	return nil
+}
+
+func (ka *KRLAgent) updated() {
+	ka.lastUpdated = time.Now()
+
+	if ka.alerted {
+		log.Printf("KRL is up to date again")
+		ka.alerted = false
+	}
+}
+
+// alert once if KRL has not been successfully updated for stale_after seconds
+func (ka *KRLAgent) checkStale() {
+	ka.lock.Lock()
+	defer ka.lock.Unlock()
+
+	if ka.cfg.StaleAfter == 0 || ka.alerted {
+		return
+	}
+
+	since := time.Since(ka.lastUpdated)
+	if since < time.Duration(ka.cfg.StaleAfter)*time.Second {
+		return
+	}
+
+	ka.alerted = true
+	log.Printf("ALERT: KRL %s is stale, last updated %s ago", ka.cfg.RevokedKeysPath, since.Round(time.Second))
+
+	if ka.cfg.AlertHook != "" {
+		err := runHook(ka.cfg.AlertHook)
+		if err != nil {
+			log.Printf("alert hook failed: %v", err)
+		}
+	}
+}
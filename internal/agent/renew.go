@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/client"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+const minBackoff = 10 * time.Second
+
+type backoff struct {
+	failures    int
+	nextAttempt time.Time
+}
+
+// HostCertRenewer requests fresh host certificates before the present ones expire
+type HostCertRenewer struct {
+	client   *client.Client
+	cfg      *config.RenewConfig
+	tasks    *utils.ScheduledTaskGroup
+	backoffs map[string]*backoff
+	lock     *sync.Mutex
+}
+
+func NewHostCertRenewer(cfg *config.RenewConfig) (*HostCertRenewer, error) {
+	c, err := client.New(cfg.Server, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.RenewFraction <= 0 || cfg.RenewFraction >= 1 {
+		cfg.RenewFraction = 0.5
+	}
+
+	if cfg.CheckInterval == 0 {
+		cfg.CheckInterval = 60
+	}
+
+	return &HostCertRenewer{
+		client:   c,
+		cfg:      cfg,
+		tasks:    utils.NewScheduledTaskGroup("renew"),
+		backoffs: make(map[string]*backoff),
+		lock:     &sync.Mutex{},
+	}, nil
+}
+
+func (r *HostCertRenewer) Start() {
+	r.checkAll()
+
+	r.tasks.AddPerodical(time.Duration(r.cfg.CheckInterval)*time.Second, func() error {
+		r.checkAll()
+		return nil
+	})
+}
+
+func (r *HostCertRenewer) Stop() {
+	r.tasks.WaitAndStop()
+}
+
+// renew certificates which are due and run reload hook if any of them renewed
+func (r *HostCertRenewer) checkAll() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	renewed := false
+
+	for _, k := range r.cfg.Keys {
+		bo, exist := r.backoffs[k.PublicKeyPath]
+		if !exist {
+			bo = &backoff{}
+			r.backoffs[k.PublicKeyPath] = bo
+		}
+
+		if time.Now().Before(bo.nextAttempt) {
+			continue
+		}
+
+		ok, err := r.renewIfDue(k)
+		if err != nil {
+			bo.failures++
+			bo.nextAttempt = time.Now().Add(r.backoffDelay(bo.failures))
+			log.Printf("renew %s failed (%d times), retry after %s: %v", k.PublicKeyPath, bo.failures, bo.nextAttempt.Format(time.RFC3339), err)
+
+			continue
+		}
+
+		bo.failures = 0
+		bo.nextAttempt = time.Time{}
+		renewed = renewed || ok
+	}
+
+	if renewed && r.cfg.ReloadHook != "" {
+		err := runHook(r.cfg.ReloadHook)
+		if err != nil {
+			log.Printf("reload hook failed: %v", err)
+		}
+	}
+}
+
+// exponential backoff capped by max_backoff
+func (r *HostCertRenewer) backoffDelay(failures int) time.Duration {
+	maxDelay := time.Duration(r.cfg.MaxBackoff) * time.Second
+	if maxDelay < minBackoff {
+		maxDelay = minBackoff
+	}
+
+	delay := minBackoff
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay
+}
+
+func certPathOf(k *config.RenewKeyConfig) string {
+	if k.CertPath != "" {
+		return k.CertPath
+	}
+
+	return strings.TrimSuffix(k.PublicKeyPath, ".pub") + "-cert.pub"
+}
+
+func (r *HostCertRenewer) renewIfDue(k *config.RenewKeyConfig) (bool, error) {
+	pubkeyBytes, err := os.ReadFile(k.PublicKeyPath)
+	if err != nil {
+		return false, err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey(pubkeyBytes)
+	if err != nil {
+		return false, err
+	}
+
+	certPath := certPathOf(k)
+	due, valid := r.isDue(certPath, pubkey)
+	if !due {
+		return false, nil
+	}
+
+	var signed *model.Cert
+	if valid && r.cfg.RenewWithCert {
+		signed, err = r.renewWithCert(k, certPath)
+	} else {
+		signed, err = r.signWithToken(k, pubkeyBytes)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = utils.WriteFileAtomic(certPath, []byte(signed.Content+"\n"), 0644)
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("renewed %s, key id %s, valid until %s", certPath, signed.KeyId, signed.ValidEnd.Format(time.RFC3339))
+
+	return true, nil
+}
+
+func (r *HostCertRenewer) renewWithCert(k *config.RenewKeyConfig, certPath string) (*model.Cert, error) {
+	certBytes, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := loadHostKeySigner(strings.TrimSuffix(k.PublicKeyPath, ".pub"))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.client.Renew("host", certBytes, signer)
+}
+
+func (r *HostCertRenewer) signWithToken(k *config.RenewKeyConfig, pubkeyBytes []byte) (signed *model.Cert, err error) {
+	sr := &client.SignRequest{
+		Role:       "host",
+		PublicKey:  pubkeyBytes,
+		Principals: k.Principals,
+		TTL:        time.Duration(k.TTL) * time.Second,
+	}
+
+	if r.cfg.SignNonce {
+		sr.Signer, err = loadHostKeySigner(strings.TrimSuffix(k.PublicKeyPath, ".pub"))
+		if err != nil {
+			return
+		}
+	}
+
+	return r.client.Sign(sr)
+}
+
+// certificate is due if it's missing, not for the key or the renew fraction of its validity elapsed.
+// valid reports whether the present certificate is for the key and not expired yet
+func (r *HostCertRenewer) isDue(certPath string, pubkey ssh.PublicKey) (due bool, valid bool) {
+	certBytes, err := os.ReadFile(certPath)
+	if err != nil {
+		return true, false
+	}
+
+	cert, err := utils.ParseSSHCert(certBytes)
+	if err != nil {
+		return true, false
+	}
+
+	if !bytes.Equal(cert.Key.Marshal(), pubkey.Marshal()) {
+		return true, false
+	}
+
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		return false, true
+	}
+
+	start := time.Unix(int64(cert.ValidAfter), 0)
+	end := time.Unix(int64(cert.ValidBefore), 0)
+	renewAt := start.Add(time.Duration(float64(end.Sub(start)) * r.cfg.RenewFraction))
+
+	return !time.Now().Before(renewAt), time.Now().Before(end)
+}
+
+func loadHostKeySigner(privkeyFile string) (ssh.Signer, error) {
+	privkeyBytes, err := os.ReadFile(privkeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKey(privkeyBytes)
+}
+
+func runHook(hook string) error {
+	cmd := exec.Command("sh", "-c", hook)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
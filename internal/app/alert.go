@@ -0,0 +1,34 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/event"
+)
+
+// high priority events the alert hook runs on, none of them is dropped while the hook is running
+var alertTypes = []string{event.TypeBreakGlass}
+
+// run the alert hook for every event received, event is passed by environment variables
+func runAlertHook(hook string, events <-chan *event.Event) {
+	for e := range events {
+		data, err := json.Marshal(e.Data)
+		if err != nil {
+			log.Printf("alert hook: %v", err)
+			continue
+		}
+
+		cmd := exec.Command("sh", "-c", hook)
+		cmd.Env = append(os.Environ(), "CA_EVENT_TYPE="+e.Type, "CA_EVENT_DATA="+string(data))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+		if err != nil {
+			log.Printf("alert hook failed on %s event: %v", e.Type, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/event"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/identity"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/token"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	instance *App
+	once     sync.Once
+)
+
+// App holds the CA services and stores shared by the server and offline commands
+type App struct {
+	UserCA *service.SSHCertCAService
+	HostCA *service.SSHCertCAService
+	Auth   *auth.Authenticator
+	// requests held back for approval
+	Approvals *service.ApprovalService
+	// nil if break-glass is not configured
+	BreakGlass *service.BreakGlassService
+	Events     *event.Bus
+
+	stopAlerts func()
+
+	// proof of possession nonces
+	Challenges *verify.ChallengeStore
+}
+
+func New(cfg *config.Config) (a *App, err error) {
+	a = &App{}
+
+	a.UserCA, err = service.NewSSHCertCAService(cfg.DBconfig.Driver, cfg.DBconfig.DSN,
+		cfg.UserCA.PrivateKeyPath, "", model.CerTypeUser)
+	if err != nil {
+		return nil, err
+	}
+
+	a.HostCA, err = service.NewSSHCertCAService(cfg.DBconfig.Driver, cfg.DBconfig.DSN,
+		cfg.HostCA.PrivateKeyPath, "", model.CertTypeHost)
+	if err != nil {
+		a.UserCA.Stop()
+		return nil, err
+	}
+
+	for _, c := range []struct {
+		ca  *service.SSHCertCAService
+		cfg *config.CAConfig
+	}{{a.UserCA, cfg.UserCA}, {a.HostCA, cfg.HostCA}} {
+		keys, err := parsePreviousKeys(c.cfg.PreviousPublicKeys)
+		if err != nil {
+			a.UserCA.Stop()
+			a.HostCA.Stop()
+			return nil, err
+		}
+		c.ca.SetPreviousKeys(keys)
+
+		if c.cfg.KeyIdTemplate == "" {
+			continue
+		}
+
+		err = c.ca.SetKeyIdTemplate(c.cfg.KeyIdTemplate)
+		if err != nil {
+			a.UserCA.Stop()
+			a.HostCA.Stop()
+			return nil, err
+		}
+	}
+
+	if cfg.HostCA.VerifyHostKey {
+		a.HostCA.SetHostKeyVerifier(verify.NewSSHHandshakeVerifier(cfg.HostCA.VerifyPort,
+			time.Duration(cfg.HostCA.VerifyTimeout)*time.Second))
+	}
+
+	var resolver identity.Resolver
+	if cfg.LDAP != nil {
+		resolver = newLDAPResolver(cfg.LDAP)
+	}
+
+	// approval rules may be set at runtime, so both CAs have a policy
+	a.UserCA.SetPolicy(newPolicy(resolver, cfg.UserCA.Approval))
+	a.HostCA.SetPolicy(newPolicy(nil, cfg.HostCA.Approval))
+
+	a.Approvals = service.NewApprovalService(cfg.DBconfig.Driver, cfg.DBconfig.DSN, a.UserCA, a.HostCA)
+
+	a.Events = event.NewBus()
+	a.UserCA.SetEventBus(a.Events)
+	a.HostCA.SetEventBus(a.Events)
+
+	if cfg.AlertHook != "" {
+		var events <-chan *event.Event
+		events, a.stopAlerts = a.Events.SubscribeQueued(alertTypes...)
+		go runAlertHook(cfg.AlertHook, events)
+	}
+
+	if cfg.BreakGlass != nil {
+		a.BreakGlass = service.NewBreakGlassService(cfg.DBconfig.Driver, cfg.DBconfig.DSN, a.UserCA, a.Events,
+			cfg.BreakGlass.Principal, time.Duration(cfg.BreakGlass.TTL)*time.Second, time.Duration(cfg.BreakGlass.Cooldown)*time.Second)
+	}
+
+	a.Auth = auth.NewAuthenticator(token.NewTokenRepo(cfg.DBconfig.Driver, cfg.DBconfig.DSN), cfg.AuthKey)
+	a.Challenges = verify.NewChallengeStore(time.Duration(cfg.ChallengeTTL) * time.Second)
+
+	return a, nil
+}
+
+func parsePreviousKeys(lines []string) ([]ssh.PublicKey, error) {
+	keys := make([]ssh.PublicKey, 0, len(lines))
+	for _, l := range lines {
+		key, err := utils.ParseSSHPublicKey([]byte(l))
+		if err != nil {
+			return nil, fmt.Errorf("previous_public_keys: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func newPolicy(resolver identity.Resolver, approval *config.ApprovalConfig) *policy.Engine {
+	p := policy.NewEngine(resolver)
+	p.SetApprovalRule(newApprovalRule(approval))
+
+	return p
+}
+
+func newApprovalRule(approval *config.ApprovalConfig) *policy.ApprovalRule {
+	if approval == nil {
+		return nil
+	}
+
+	return &policy.ApprovalRule{
+		SensitivePrincipals: approval.SensitivePrincipals,
+		MaxTTL:              time.Duration(approval.MaxTTL) * time.Second,
+		Required:            approval.Required,
+		Approvers:           approval.Approvers,
+		Expiry:              time.Duration(approval.Expiry) * time.Second,
+	}
+}
+
+// replace approval rule of the CA at runtime, nil disables approval
+func (a *App) SetApproval(role model.RoleType, approval *config.ApprovalConfig) error {
+	ca, err := a.CAByRole(role)
+	if err != nil {
+		return err
+	}
+
+	ca.Policy().SetApprovalRule(newApprovalRule(approval))
+
+	return nil
+}
+
+func newLDAPResolver(cfg *config.LDAPConfig) identity.Resolver {
+	r := &identity.LDAPResolver{
+		URL:                cfg.URL,
+		BindDN:             cfg.BindDN,
+		BindPassword:       cfg.BindPassword,
+		StartTLS:           cfg.StartTLS,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		UserBaseDN:         cfg.UserBaseDN,
+		UserFilter:         cfg.UserFilter,
+		GroupBaseDN:        cfg.GroupBaseDN,
+		GroupFilter:        cfg.GroupFilter,
+		GroupAttr:          cfg.GroupAttr,
+		Groups:             cfg.Groups,
+	}
+
+	return identity.NewCachedResolver(r, time.Duration(cfg.CacheTTL)*time.Second)
+}
+
+// get the shared app instance built from config.Cfg
+func Get() *App {
+	once.Do(func() {
+		var err error
+		instance, err = New(config.Cfg)
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	return instance
+}
+
+func (a *App) CAByRole(role model.RoleType) (*service.SSHCertCAService, error) {
+	switch role {
+	case model.CertTypeHost:
+		return a.HostCA, nil
+	case model.CerTypeUser:
+		return a.UserCA, nil
+	}
+
+	return nil, model.ErrUnsupportedCertType
+}
+
+func (a *App) Close() {
+	if a.stopAlerts != nil {
+		a.stopAlerts()
+	}
+	if a.BreakGlass != nil {
+		a.BreakGlass.Stop()
+	}
+	a.Approvals.Stop()
+	a.HostCA.Stop()
+	a.UserCA.Stop()
+	a.Auth.Close()
+}
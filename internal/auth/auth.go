@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/token"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/keyauth/v2"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidAuthKey = errs.New(errs.CodeUnauthorized, "invalid auth key")
+var ErrInsufficientScope = errs.New(errs.CodeInsufficientScope, "insufficient scope")
+
+const localsToken = "auth_token"
+
+// token of the auth key in config file
+var masterToken = model.Token{
+	Id:     "master",
+	Name:   "config auth_key",
+	Scopes: model.ScopeAdmin,
+}
+
+type Authenticator struct {
+	tokens    token.TokenRepo
+	masterKey string
+}
+
+func NewAuthenticator(tokens token.TokenRepo, masterKey string) *Authenticator {
+	return &Authenticator{
+		tokens:    tokens,
+		masterKey: masterKey,
+	}
+}
+
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// look up the token of given secret, either the auth key in config or an issued token
+func (a *Authenticator) Authenticate(secret string) (*model.Token, error) {
+	if secret == "" {
+		return nil, ErrInvalidAuthKey
+	}
+
+	if a.masterKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.masterKey)) == 1 {
+		t := masterToken
+		return &t, nil
+	}
+
+	t, err := a.tokens.GetTokenByHash(HashToken(secret))
+	if err != nil {
+		return nil, ErrInvalidAuthKey
+	}
+
+	if t.IsExpired() {
+		return nil, ErrInvalidAuthKey
+	}
+
+	return t, nil
+}
+
+// issue a new token, the secret is only returned here and never stored
+func (a *Authenticator) CreateToken(name, scopes string, ttl time.Duration) (secret string, t model.Token, err error) {
+	if scopes == "" {
+		scopes = model.DefaultScope
+	}
+
+	secret = utils.RandomSha1Hex()
+	t = model.Token{
+		Id:        uuid.NewString(),
+		Name:      name,
+		Hash:      HashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		t.ExpiresAt = t.CreatedAt.Add(ttl)
+	}
+
+	err = a.tokens.CreateToken(t)
+
+	return
+}
+
+func (a *Authenticator) ListTokens() ([]*model.Token, error) {
+	return a.tokens.GetTokens()
+}
+
+func (a *Authenticator) DeleteToken(id string) error {
+	return a.tokens.DeleteToken(id)
+}
+
+func (a *Authenticator) Close() error {
+	return a.tokens.Close()
+}
+
+// bearer token auth middleware, the authenticated token is kept in ctx locals.
+// request already authenticated by another group is passed through
+func (a *Authenticator) Middleware() fiber.Handler {
+	handler := keyauth.New(keyauth.Config{
+		Validator: func(c *fiber.Ctx, s string) (bool, error) {
+			t, err := a.Authenticate(s)
+			if err != nil {
+				return false, err
+			}
+
+			c.Locals(localsToken, t)
+
+			return true, nil
+		},
+		SuccessHandler: func(c *fiber.Ctx) error {
+			log.Printf("auth success from %s: %s %s", c.IP(), c.Method(), c.Path())
+
+			return c.Next()
+		},
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			log.Printf("auth fail from %s: %s %s", c.IP(), c.Method(), c.Path())
+
+			if !errors.Is(err, ErrInvalidAuthKey) {
+				err = ErrInvalidAuthKey.Wrap(err)
+			}
+
+			return err
+		},
+	})
+
+	return func(c *fiber.Ctx) error {
+		if TokenFromCtx(c) != nil {
+			return c.Next()
+		}
+
+		return handler(c)
+	}
+}
+
+// reject request if the authenticated token lacks of given scope
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		t := TokenFromCtx(c)
+		if t == nil || !t.HasScope(scope) {
+			return ErrInsufficientScope
+		}
+
+		return c.Next()
+	}
+}
+
+func TokenFromCtx(c *fiber.Ctx) *model.Token {
+	t, ok := c.Locals(localsToken).(*model.Token)
+	if !ok {
+		return nil
+	}
+
+	return t
+}
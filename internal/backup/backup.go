@@ -0,0 +1,213 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+const formatVersion = 1
+
+// archive entries
+const (
+	manifestFile = "manifest.json"
+	configFile   = "config.json"
+	dbFile       = "db/state.db"
+)
+
+func publicKeyFile(role string) string  { return "ca/" + role + ".pub" }
+func privateKeyFile(role string) string { return "ca/" + role }
+func krlFile(role string) string        { return "krl/" + role + ".krl" }
+
+var roles = []model.RoleType{model.CerTypeUser, model.CertTypeHost}
+
+type Manifest struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	// latest issuance, revocation, token, approval request or import recorded in the DB
+	StateTime   time.Time         `json:"state_time"`
+	KRLVersions map[string]uint64 `json:"krl_versions"`
+	PrivateKeys bool              `json:"private_keys"`
+	Encrypted   bool              `json:"encrypted"`
+	// sha256 of every other entry
+	Files map[string]string `json:"files"`
+}
+
+type Options struct {
+	// CA private keys are left out unless it's set
+	PrivateKeys bool
+	// archive is encrypted with the passphrase if it's not empty
+	Passphrase []byte
+}
+
+// Create writes config, CA keys, a snapshot of the DB, holding certificates, revocations,
+// tokens and audit log, and the present KRLs to a gzipped tar archive
+func Create(a *app.App, cfg *config.Config, opts *Options) ([]byte, *Manifest, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	files := make(map[string][]byte)
+
+	db, err := snapshotDB(cfg.DBconfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	files[dbFile] = db
+
+	cfgBytes, err := json.MarshalIndent(cfg, "", " ")
+	if err != nil {
+		return nil, nil, err
+	}
+	files[configFile] = cfgBytes
+
+	m := &Manifest{
+		Format:      formatVersion,
+		CreatedAt:   time.Now(),
+		KRLVersions: make(map[string]uint64),
+		PrivateKeys: opts.PrivateKeys,
+		Encrypted:   len(opts.Passphrase) > 0,
+		Files:       make(map[string]string),
+	}
+
+	for _, role := range roles {
+		ca, err := a.CAByRole(role)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := model.FormatType(role)
+
+		files[publicKeyFile(name)] = []byte(ca.PublicKeyAsAuthKeyStr() + "\n")
+
+		krl, version := ca.GetPresentRevokedListWithVersion()
+		files[krlFile(name)] = krl
+		m.KRLVersions[name] = version
+
+		if opts.PrivateKeys {
+			files[privateKeyFile(name)], err = os.ReadFile(caConfig(cfg, role).PrivateKeyPath)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	m.StateTime, err = snapshotStateTime(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for name, data := range files {
+		m.Files[name] = checksum(data)
+	}
+
+	archive, err := pack(m, files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if m.Encrypted {
+		archive, err = encrypt(archive, opts.Passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return archive, m, nil
+}
+
+func caConfig(cfg *config.Config, role model.RoleType) *config.CAConfig {
+	if role == model.CertTypeHost {
+		return cfg.HostCA
+	}
+
+	return cfg.UserCA
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// time of state of DB snapshot
+func snapshotStateTime(db []byte) (time.Time, error) {
+	tmp, err := os.CreateTemp("", "ssh_cert_ca-backup-*.db")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(db)
+	tmp.Close()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return stateTime(tmp.Name())
+}
+
+// manifest goes first, then other entries by name
+func pack(m *Manifest, files map[string][]byte) ([]byte, error) {
+	manifest, err := json.MarshalIndent(m, "", " ")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	write := func(name string, data []byte, mode int64) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    mode,
+			Size:    int64(len(data)),
+			ModTime: m.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(data)
+		return err
+	}
+
+	err = write(manifestFile, manifest, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		err = write(name, files[name], 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encrypted archive: magic, scrypt salt, nonce, then XChaCha20-Poly1305 sealed archive
+const encMagic = "ssh-cert-ca-backup-encrypted-v1\n"
+
+const saltSize = 16
+
+func deriveKey(passphrase, salt []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, 1<<15, 8, 1, chacha20poly1305.KeySize)
+}
+
+// whether the archive needs a passphrase to be restored
+func IsEncrypted(data []byte) bool {
+	return strings.HasPrefix(string(data), encMagic)
+}
+
+func encrypt(plain, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(encMagic), salt...)
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, plain, []byte(encMagic)), nil
+}
+
+func decrypt(data, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+
+	data = data[len(encMagic):]
+	if len(data) < saltSize+chacha20poly1305.NonceSizeX {
+		return nil, ErrCorrupted
+	}
+
+	key, err := deriveKey(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := data[saltSize : saltSize+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[saltSize+aead.NonceSize():], []byte(encMagic))
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// columns telling when records were made, the latest of them is the time of state
+var stateColumns = map[string]string{
+	"certs":                  "valid_start",
+	"revocations":            "revoked_at",
+	"tokens":                 "created_at",
+	"cert_requests":          "created_at",
+	"cert_request_approvals": "approved_at",
+	"break_glass":            "created_at",
+	"imported_krls":          "imported_at",
+}
+
+// path of the sqlite DB file in dsn, e.g. file:certs.db?mode=rwc
+func dbPath(cfg *config.DBConfig) (string, error) {
+	if cfg == nil || cfg.Driver != "sqlite3" {
+		return "", ErrUnsupportedDriver
+	}
+
+	path := strings.TrimPrefix(cfg.DSN, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		query, err := url.ParseQuery(path[i+1:])
+		if err == nil && query.Get("mode") == "memory" {
+			return "", ErrUnsupportedDriver
+		}
+
+		path = path[:i]
+	}
+
+	if path == "" || path == ":memory:" {
+		return "", ErrUnsupportedDriver
+	}
+
+	return path, nil
+}
+
+// consistent copy of the DB, taken while it's in use
+func snapshotDB(cfg *config.DBConfig) ([]byte, error) {
+	_, err := dbPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp("", "ssh_cert_ca-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	db, err := sqlx.Connect("sqlite", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	out := filepath.Join(tmp, "state.db")
+	_, err = db.Exec("VACUUM INTO ?", out)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(out)
+}
+
+// time of the latest record in the sqlite DB file, zero if there is none
+func stateTime(path string) (latest time.Time, err error) {
+	db, err := sqlx.Connect("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	for table, column := range stateColumns {
+		var exist int
+		err = db.Get(&exist, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+		if err != nil {
+			return
+		}
+		if exist == 0 {
+			continue
+		}
+
+		var times []time.Time
+		err = db.Select(&times, fmt.Sprintf("SELECT %s FROM %s", column, table))
+		if err != nil {
+			return
+		}
+
+		for _, t := range times {
+			if t.After(latest) {
+				latest = t
+			}
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,11 @@
+package backup
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var ErrUnsupportedDriver = errs.New(errs.CodeUnavailable, "backup supports sqlite3 DB only")
+var ErrCorrupted = errs.New(errs.CodeInvalidInput, "backup archive is corrupted")
+var ErrPassphraseRequired = errs.New(errs.CodeInvalidInput, "backup archive is encrypted, passphrase is required")
+var ErrBadPassphrase = errs.New(errs.CodeInvalidInput, "wrong passphrase or tampered backup archive")
+var ErrNewerState = errs.New(errs.CodeConflict, "present state is newer than the backup")
+var ErrKeyMismatch = errs.New(errs.CodeConflict, "CA key in place does not match the backup")
+var ErrMissingKey = errs.New(errs.CodeNotFound, "CA private key is neither in place nor in the backup")
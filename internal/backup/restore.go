@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+// verified content of a backup archive
+type Archive struct {
+	Manifest *Manifest
+	Config   *config.Config
+
+	files map[string][]byte
+}
+
+// Open decrypts the archive if it's encrypted and verifies every entry against the manifest
+func Open(data, passphrase []byte) (ar *Archive, err error) {
+	if IsEncrypted(data) {
+		data, err = decrypt(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupted.Wrap(err)
+	}
+
+	ar = &Archive{files: make(map[string][]byte)}
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrCorrupted.Wrap(err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, ErrCorrupted.Wrap(fmt.Errorf("unexpected entry %s", hdr.Name))
+		}
+		if _, dup := ar.files[hdr.Name]; dup {
+			return nil, ErrCorrupted.Wrap(fmt.Errorf("duplicated entry %s", hdr.Name))
+		}
+
+		ar.files[hdr.Name], err = io.ReadAll(tr)
+		if err != nil {
+			return nil, ErrCorrupted.Wrap(err)
+		}
+	}
+
+	err = ar.verify()
+	if err != nil {
+		return nil, ErrCorrupted.Wrap(err)
+	}
+
+	return ar, nil
+}
+
+func (ar *Archive) verify() error {
+	manifest, exist := ar.files[manifestFile]
+	if !exist {
+		return errors.New("no manifest")
+	}
+	delete(ar.files, manifestFile)
+
+	ar.Manifest = &Manifest{}
+	err := json.Unmarshal(manifest, ar.Manifest)
+	if err != nil {
+		return err
+	}
+
+	if ar.Manifest.Format != formatVersion {
+		return fmt.Errorf("unsupported format %d", ar.Manifest.Format)
+	}
+
+	// nothing is left out or slipped in
+	required := []string{configFile, dbFile}
+	for _, role := range roles {
+		name := model.FormatType(role)
+		required = append(required, publicKeyFile(name), krlFile(name))
+		if ar.Manifest.PrivateKeys {
+			required = append(required, privateKeyFile(name))
+		}
+	}
+	for _, name := range required {
+		if _, exist := ar.Manifest.Files[name]; !exist {
+			return fmt.Errorf("%s is not in manifest", name)
+		}
+	}
+
+	for name, data := range ar.files {
+		sum, exist := ar.Manifest.Files[name]
+		if !exist {
+			return fmt.Errorf("%s is not in manifest", name)
+		}
+		if sum != checksum(data) {
+			return fmt.Errorf("checksum of %s mismatch", name)
+		}
+	}
+	for name := range ar.Manifest.Files {
+		if _, exist := ar.files[name]; !exist {
+			return fmt.Errorf("%s is missing", name)
+		}
+	}
+
+	ar.Config = &config.Config{}
+	return json.Unmarshal(ar.files[configFile], ar.Config)
+}
+
+// Restore writes the DB, CA keys and config back to where the archived config says, config goes to cfgFile.
+// It refuses to go back from a newer state or replace CA keys in place unless forced.
+// The server must be stopped
+func (ar *Archive) Restore(cfgFile string, force bool) error {
+	db, err := dbPath(ar.Config.DBconfig)
+	if err != nil {
+		return err
+	}
+
+	if utils.IsFileExist(db) {
+		present, err := stateTime(db)
+		if err != nil {
+			return err
+		}
+
+		if present.After(ar.Manifest.StateTime) && !force {
+			return ErrNewerState.Wrap(fmt.Errorf("present state at %s, backup at %s",
+				present.Format(time.RFC3339), ar.Manifest.StateTime.Format(time.RFC3339)))
+		}
+	}
+
+	// check keys before anything is written
+	keys := make(map[string][]byte)
+	for _, role := range roles {
+		name := model.FormatType(role)
+		path := caConfig(ar.Config, role).PrivateKeyPath
+
+		key, err := ar.keyToRestore(name, path, force)
+		if err != nil {
+			return fmt.Errorf("%s CA: %w", name, err)
+		}
+		if key != nil {
+			keys[path] = key
+		}
+	}
+
+	// stale WAL must not be replayed on the restored DB
+	for _, suffix := range []string{"-wal", "-shm"} {
+		err = os.Remove(db + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	err = utils.WriteFileAtomic(db, ar.files[dbFile], 0644)
+	if err != nil {
+		return err
+	}
+
+	for path, key := range keys {
+		err = utils.WriteFileAtomic(path, key, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	return utils.WriteFileAtomic(cfgFile, ar.files[configFile], 0644)
+}
+
+// archived private key if it's to be written to path, nil if the key in place is kept
+func (ar *Archive) keyToRestore(role, path string, force bool) ([]byte, error) {
+	archived, _, _, _, err := ssh.ParseAuthorizedKey(ar.files[publicKeyFile(role)])
+	if err != nil {
+		return nil, ErrCorrupted.Wrap(err)
+	}
+
+	key, hasKey := ar.files[privateKeyFile(role)]
+	if hasKey {
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, ErrCorrupted.Wrap(err)
+		}
+		if !bytes.Equal(signer.PublicKey().Marshal(), archived.Marshal()) {
+			return nil, ErrCorrupted.Wrap(errors.New("private key does not match public key"))
+		}
+	}
+
+	if !utils.IsFileExist(path) {
+		if !hasKey {
+			return nil, ErrMissingKey
+		}
+		return key, nil
+	}
+
+	present, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(present)
+	if err == nil && bytes.Equal(signer.PublicKey().Marshal(), archived.Marshal()) {
+		return nil, nil
+	}
+
+	if !hasKey {
+		return nil, ErrKeyMismatch
+	}
+	if !force {
+		return nil, ErrKeyMismatch.Wrap(fmt.Errorf("%s would be replaced", path))
+	}
+
+	return key, nil
+}
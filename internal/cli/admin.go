@@ -0,0 +1,437 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+)
+
+var errMissingArg = errors.New("missing argument")
+var errUnknownScope = errors.New("unknown scope")
+
+func init() {
+	RegisterCommand(&Command{Name: "init", Usage: "generate config, CA keys and DB", Run: cmdInit})
+	RegisterCommand(&Command{Name: "sign", Usage: "sign a public key file", Run: cmdSign})
+	RegisterCommand(&Command{Name: "revoke", Usage: "revoke a certificate by key id", Run: cmdRevoke})
+	RegisterCommand(&Command{Name: "list", Usage: "list issued certificates", Run: cmdList})
+	RegisterCommand(&Command{Name: "krl", Usage: "export or keep in sync the key revocation list", Run: cmdKRL})
+	RegisterCommand(&Command{Name: "pubkey", Usage: "print CA public key", Run: cmdPubkey})
+	RegisterCommand(&Command{Name: "inspect", Usage: "decode a certificate", Run: cmdInspect})
+	RegisterCommand(&Command{Name: "token", Usage: "manage API tokens", Run: cmdToken})
+	RegisterCommand(&Command{Name: "import", Usage: "import certificates and KRLs signed by the CA key elsewhere", Run: cmdImport})
+}
+
+// run fn with the CA service of given role
+func withCA(role string, fn func(a *app.App, ca *service.SSHCertCAService) error) error {
+	ct, err := model.ParseCertType(role)
+	if err != nil {
+		return err
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ca, err := a.CAByRole(ct)
+	if err != nil {
+		return err
+	}
+
+	return fn(a, ca)
+}
+
+func readInput(fname string) ([]byte, error) {
+	if fname == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(fname)
+}
+
+func writeOutput(fname string, data []byte, perm os.FileMode) error {
+	if fname == "" || fname == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	return os.WriteFile(fname, data, perm)
+}
+
+func cmdInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.Parse(args)
+
+	if utils.IsFileExist(configFile) {
+		fmt.Fprintf(os.Stderr, "using existing config %s\n", configFile)
+	} else {
+		fmt.Fprintf(os.Stderr, "generating config %s\n", configFile)
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	fmt.Printf("user CA (%s): %s\n", config.Cfg.UserCA.PrivateKeyPath, a.UserCA.PublicKeyAsAuthKeyStr())
+	fmt.Printf("host CA (%s): %s\n", config.Cfg.HostCA.PrivateKeyPath, a.HostCA.PublicKeyAsAuthKeyStr())
+	fmt.Printf("auth key: %s\n", config.Cfg.AuthKey)
+
+	return nil
+}
+
+func cmdSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	role := fs.String("role", "user", "certificate role, user or host")
+	principals := fs.String("principals", "", "comma separated list of principals")
+	identity := fs.String("identity", "", "requester identity resolved to principals by the policy, e.g. ldap user")
+	ttl := fs.Duration("ttl", 24*365*time.Hour, "certificate lifetime")
+	keyid := fs.String("keyid", "", "certificate key id, rendered from keyid_template of the CA if empty")
+	out := fs.String("out", "", "output file, stdout if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: sign [flags] <pubkey file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || (*principals == "" && *identity == "") {
+		fs.Usage()
+		return errMissingArg
+	}
+
+	in, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey(in)
+	if err != nil {
+		return err
+	}
+
+	return withCA(*role, func(a *app.App, ca *service.SSHCertCAService) error {
+		req := &service.SignRequest{
+			PublicKey: pubkey,
+			KeyId:     *keyid,
+			Requester: localRequester(),
+			Identity:  *identity,
+			TTL:       *ttl,
+			// operator with access to CA keys needs no approval,
+			// nor identity resolution if principals are given without identity
+			Approved: true,
+			Granted:  *identity == "",
+		}
+		if *principals != "" {
+			req.Principals = strings.Split(*principals, ",")
+		}
+
+		cert, err := ca.Issue(req)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "signed %s, valid until %s\n", cert.KeyId, cert.ValidEnd.Format(time.RFC3339))
+
+		return writeOutput(*out, []byte(cert.Content+"\n"), 0644)
+	})
+}
+
+func cmdRevoke(args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	role := fs.String("role", "user", "certificate role, user or host")
+	reason := fs.String("reason", "", "reason of revocation")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: revoke [flags] <key id>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errMissingArg
+	}
+
+	return withCA(*role, func(a *app.App, ca *service.SSHCertCAService) error {
+		for _, id := range fs.Args() {
+			err := ca.RevokeWithReason(id, *reason, "offline admin")
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+
+			fmt.Fprintf(os.Stderr, "revoked %s\n", id)
+		}
+
+		return nil
+	})
+}
+
+func cmdList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	role := fs.String("role", "user", "certificate role, user or host")
+	revoked := fs.Bool("revoked", false, "list revoked key ids instead")
+	fs.Parse(args)
+
+	return withCA(*role, func(a *app.App, ca *service.SSHCertCAService) error {
+		if *revoked {
+			ids, err := ca.ListRevokedCertIds()
+			if err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+
+			return nil
+		}
+
+		certs, err := ca.ListCerts()
+		if err != nil {
+			return err
+		}
+
+		for _, c := range certs {
+			if c.Revoked {
+				continue
+			}
+
+			principals := ""
+			if sc, err := utils.ParseSSHCert([]byte(c.Content)); err == nil {
+				principals = strings.Join(sc.ValidPrincipals, ",")
+			}
+
+			fmt.Printf("%s\t%s\t%s\t%s\n", c.KeyId, c.ValidStart.Format(time.RFC3339), c.ValidEnd.Format(time.RFC3339), principals)
+		}
+
+		return nil
+	})
+}
+
+func cmdKRL(args []string) error {
+	if len(args) > 0 && args[0] == "agent" {
+		return cmdKRLAgent(args[1:])
+	}
+
+	if len(args) < 1 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "usage: krl export|agent [flags]")
+		return errMissingArg
+	}
+
+	fs := flag.NewFlagSet("krl export", flag.ExitOnError)
+	role := fs.String("role", "user", "certificate role, user or host")
+	out := fs.String("out", "", "output file, stdout if empty")
+	fs.Parse(args[1:])
+
+	return withCA(*role, func(a *app.App, ca *service.SSHCertCAService) error {
+		return writeOutput(*out, ca.GetPresentRevokedList(), 0644)
+	})
+}
+
+func cmdPubkey(args []string) error {
+	fs := flag.NewFlagSet("pubkey", flag.ExitOnError)
+	role := fs.String("role", "user", "certificate role, user or host")
+	fs.Parse(args)
+
+	return withCA(*role, func(a *app.App, ca *service.SSHCertCAService) error {
+		fmt.Println(ca.PublicKeyAsAuthKeyStr())
+		return nil
+	})
+}
+
+func cmdInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: inspect <cert file|->")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errMissingArg
+	}
+
+	in, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cert, err := utils.ParseSSHCert(in)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(utils.FormatSSHCert(cert))
+
+	return nil
+}
+
+func cmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	role := fs.String("role", "user", "certificate role, user or host")
+	signKRL := fs.Bool("sign-krl", false, "sign unsigned KRLs with the CA key before importing, e.g. ones made by ssh-keygen -k")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: import [flags] <cert or KRL file>...")
+		fmt.Fprintln(fs.Output(), "certificate files hold one certificate per line, KRL files are told apart by their content")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errMissingArg
+	}
+
+	return withCA(*role, func(a *app.App, ca *service.SSHCertCAService) error {
+		failed := 0
+		for _, fname := range fs.Args() {
+			in, err := readInput(fname)
+			if err != nil {
+				return err
+			}
+
+			if utils.IsKRL(in) {
+				if *signKRL {
+					in, err = ca.SignKRL(in)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s: %s\n", fname, err)
+						failed++
+						continue
+					}
+				}
+
+				rec, revoked, err := ca.ImportKRL(in)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", fname, err)
+					failed++
+					continue
+				}
+
+				fmt.Fprintf(os.Stderr, "%s: imported KRL %s, revoked %d stored certificates\n", fname, rec.Id, len(revoked))
+				for _, id := range revoked {
+					fmt.Fprintf(os.Stderr, "revoked %s\n", id)
+				}
+
+				continue
+			}
+
+			for i, line := range strings.Split(string(in), "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+
+				cert, err := utils.ParseSSHCert([]byte(line))
+				if err == nil {
+					_, err = ca.ImportCert(cert)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s:%d: %s\n", fname, i+1, err)
+					failed++
+					continue
+				}
+
+				state := "imported"
+				if ca.IsRevoked(cert) {
+					state = "imported revoked"
+				}
+				fmt.Fprintf(os.Stderr, "%s %s\n", state, cert.KeyId)
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d imports failed", failed)
+		}
+
+		return nil
+	})
+}
+
+func cmdToken(args []string) error {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: token create|list|delete [flags]")
+		return errMissingArg
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("token create", flag.ExitOnError)
+		name := fs.String("name", "", "token name")
+		scopes := fs.String("scopes", model.DefaultScope, "comma separated list of scopes: "+strings.Join(model.Scopes, ", "))
+		ttl := fs.Duration("ttl", 0, "token lifetime, never expire if 0")
+		fs.Parse(args[1:])
+
+		if *name == "" {
+			fs.Usage()
+			return errMissingArg
+		}
+
+		if !model.ValidScopes(*scopes) {
+			return fmt.Errorf("%w: %s", errUnknownScope, *scopes)
+		}
+
+		secret, t, err := a.Auth.CreateToken(*name, *scopes, *ttl)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "created token %s (%s), it will not be shown again\n", t.Id, t.Scopes)
+		fmt.Println(secret)
+
+	case "list":
+		tokens, err := a.Auth.ListTokens()
+		if err != nil {
+			return err
+		}
+
+		for _, t := range tokens {
+			expires := "never"
+			if !t.ExpiresAt.IsZero() {
+				expires = t.ExpiresAt.Format(time.RFC3339)
+			}
+
+			fmt.Printf("%s\t%s\t%s\t%s\n", t.Id, t.Name, t.Scopes, expires)
+		}
+
+	case "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: token delete <id>")
+			return errMissingArg
+		}
+
+		return a.Auth.DeleteToken(args[1])
+
+	default:
+		return fmt.Errorf("unknown token command: %s", args[0])
+	}
+
+	return nil
+}
+
+// requester of offline commands, the local user name
+func localRequester() string {
+	u, err := user.Current()
+	if err != nil {
+		return "local"
+	}
+
+	return "local:" + u.Username
+}
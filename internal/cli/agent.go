@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"flag"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/agent"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+)
+
+func init() {
+	RegisterCommand(&Command{Name: "renew", Usage: "keep host certificates renewed", Run: cmdRenew})
+}
+
+func cmdRenew(args []string) error {
+	fs := flag.NewFlagSet("renew", flag.ExitOnError)
+	renewConfigFile := fs.String("renew-config", "renew.json", "renew config file path")
+	fs.Parse(args)
+
+	cfg, err := config.LoadRenewConfig(*renewConfigFile)
+	if err != nil {
+		return err
+	}
+
+	renewer, err := agent.NewHostCertRenewer(cfg)
+	if err != nil {
+		return err
+	}
+
+	renewer.Start()
+
+	<-utils.WaitForSignal()
+	renewer.Stop()
+
+	return nil
+}
+
+func cmdKRLAgent(args []string) error {
+	fs := flag.NewFlagSet("krl agent", flag.ExitOnError)
+	agentConfigFile := fs.String("agent-config", "krl-agent.json", "KRL agent config file path")
+	fs.Parse(args)
+
+	cfg, err := config.LoadKRLAgentConfig(*agentConfigFile)
+	if err != nil {
+		return err
+	}
+
+	ka, err := agent.NewKRLAgent(cfg)
+	if err != nil {
+		return err
+	}
+
+	ka.Start()
+
+	<-utils.WaitForSignal()
+	ka.Stop()
+
+	return nil
+}
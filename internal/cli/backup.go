@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/backup"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"golang.org/x/term"
+)
+
+var errPassphraseMismatch = errors.New("passphrases do not match")
+
+func init() {
+	RegisterCommand(&Command{Name: "backup", Usage: "back up config, CA keys, DB and KRLs to an archive", Run: cmdBackup})
+	RegisterCommand(&Command{Name: "restore", Usage: "restore a backup archive, the server must be stopped", Run: cmdRestore})
+}
+
+func cmdBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "", "archive file, - for stdout (default ssh_cert_ca-<time>.tar.gz)")
+	privateKeys := fs.Bool("private-keys", false, "include CA private keys")
+	encrypt := fs.Bool("encrypt", false, "encrypt the archive with a passphrase")
+	passphraseFile := fs.String("passphrase-file", "", "read passphrase from file instead of terminal, implies -encrypt")
+	fs.Parse(args)
+
+	var opts backup.Options
+	opts.PrivateKeys = *privateKeys
+
+	if *encrypt || *passphraseFile != "" {
+		passphrase, err := readPassphrase(*passphraseFile, true)
+		if err != nil {
+			return err
+		}
+		if len(passphrase) == 0 {
+			return backup.ErrPassphraseRequired
+		}
+		opts.Passphrase = passphrase
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	archive, m, err := backup.Create(a, config.Cfg, &opts)
+	if err != nil {
+		return err
+	}
+
+	fname := *out
+	if fname == "" {
+		fname = fmt.Sprintf("ssh_cert_ca-%s.tar.gz", m.CreatedAt.Format("20060102-150405"))
+		if m.Encrypted {
+			fname += ".enc"
+		}
+	}
+
+	err = writeOutput(fname, archive, 0600)
+	if err != nil {
+		return err
+	}
+
+	if fname != "-" {
+		fmt.Fprintf(os.Stderr, "backup written to %s\n", fname)
+	}
+	printManifest(m)
+
+	return nil
+}
+
+func cmdRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	force := fs.Bool("force", false, "restore over a newer state and replace CA keys in place")
+	passphraseFile := fs.String("passphrase-file", "", "read passphrase of encrypted archive from file instead of terminal")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: restore [flags] <archive>")
+		fmt.Fprintln(fs.Output(), "config is written to the -config path, DB and CA keys to the paths in the archived config")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errMissingArg
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var passphrase []byte
+	if backup.IsEncrypted(data) {
+		passphrase, err = readPassphrase(*passphraseFile, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	ar, err := backup.Open(data, passphrase)
+	if err != nil {
+		return err
+	}
+	printManifest(ar.Manifest)
+
+	err = ar.Restore(configFile, *force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "restored, config written to %s\n", configFile)
+
+	return nil
+}
+
+func printManifest(m *backup.Manifest) {
+	fmt.Fprintf(os.Stderr, "created at %s, state at %s\n", m.CreatedAt.Format(time.RFC3339), m.StateTime.Format(time.RFC3339))
+	fmt.Fprintf(os.Stderr, "KRL versions: user %d, host %d\n", m.KRLVersions["user"], m.KRLVersions["host"])
+	fmt.Fprintf(os.Stderr, "private keys: %t, encrypted: %t\n", m.PrivateKeys, m.Encrypted)
+}
+
+// passphrase from file, or prompted on terminal, twice if it's to be confirmed
+func readPassphrase(fname string, confirm bool) ([]byte, error) {
+	if fname != "" {
+		passphrase, err := os.ReadFile(fname)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimRight(passphrase, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Enter backup passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !confirm {
+		return passphrase, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Enter same passphrase again: ")
+	again, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(passphrase, again) {
+		return nil, errPassphraseMismatch
+	}
+
+	return passphrase, nil
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+)
+
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = make(map[string]*Command)
+
+var configFile string
+
+func RegisterCommand(c *Command) {
+	commands[c.Name] = c
+}
+
+// parse global flags and run the subcommand, the server is started if no subcommand given
+func Main(args []string) int {
+	fs := flag.NewFlagSet("ssh_cert_ca", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", "config.json", "config file path")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ssh_cert_ca [-config config.json] [command] [args]\n\ncommands:\n")
+		printCommands()
+		fmt.Fprintf(fs.Output(), "\nflags:\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	name := "serve"
+	rest := fs.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+
+	cmd, exist := commands[name]
+	if !exist {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	err = cmd.Run(rest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].Usage)
+	}
+}
+
+// load config and open CA keys and DB without starting the server
+func loadApp() (*app.App, error) {
+	var err error
+	config.Cfg, err = config.LoadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.New(config.Cfg)
+}
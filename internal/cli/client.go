@@ -0,0 +1,371 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/client"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+var errNoAgent = errors.New("SSH_AUTH_SOCK is not set")
+var errNoKeys = errors.New("no public key found")
+
+func init() {
+	RegisterCommand(&Command{Name: "client", Usage: "request user certificates from the CA server", Run: cmdClient})
+}
+
+// client flags shared by commands talking to the CA server
+type clientFlags struct {
+	server string
+	token  string
+}
+
+func (cf *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.server, "server", os.Getenv("SSH_CA_SERVER"), "CA server address, default to $SSH_CA_SERVER")
+	fs.StringVar(&cf.token, "token", os.Getenv("SSH_CA_TOKEN"), "API token, default to $SSH_CA_TOKEN")
+}
+
+func (cf *clientFlags) client() (*client.Client, error) {
+	return client.New(cf.server, cf.token)
+}
+
+type clientOptions struct {
+	principal  string
+	ttl        time.Duration
+	addToAgent bool
+	prove      bool
+	renew      bool
+	oidc       bool
+	device     bool
+}
+
+func cmdClient(args []string) error {
+	var cf clientFlags
+	var opts clientOptions
+
+	fs := flag.NewFlagSet("client", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&opts.principal, "principal", os.Getenv("USER"), "principal to sign to")
+	fs.DurationVar(&opts.ttl, "ttl", 0, "certificate lifetime, server default if 0")
+	keys := fs.String("key", "", "comma separated list of public key files, default to ~/.ssh/id_*.pub")
+	fs.BoolVar(&opts.addToAgent, "agent", false, "load key and certificate into the running ssh-agent")
+	fs.BoolVar(&opts.prove, "prove", true, "prove possession of the key by signing a challenge with ssh-agent or the key file")
+	fs.BoolVar(&opts.renew, "renew", false, "renew the present still valid certificates without token")
+	fs.BoolVar(&opts.oidc, "oidc", false, "login with OIDC in browser instead of token")
+	fs.BoolVar(&opts.device, "device", false, "login with OIDC device code flow instead of token, for headless terminals")
+	fs.Parse(args)
+
+	c, err := cf.client()
+	if err != nil {
+		return err
+	}
+
+	var pubkeyFiles []string
+	if *keys != "" {
+		pubkeyFiles = strings.Split(*keys, ",")
+	} else {
+		pubkeyFiles, err = defaultPubkeyFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(pubkeyFiles) == 0 {
+		return errNoKeys
+	}
+
+	for _, pubkeyFile := range pubkeyFiles {
+		err = requestAndInstallCert(c, pubkeyFile, &opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pubkeyFile, err)
+		}
+	}
+
+	return nil
+}
+
+func requestAndInstallCert(c *client.Client, pubkeyFile string, opts *clientOptions) error {
+	privkeyFile := strings.TrimSuffix(pubkeyFile, ".pub")
+
+	pubkeyBytes, err := os.ReadFile(pubkeyFile)
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey(pubkeyBytes)
+	if err != nil {
+		return err
+	}
+
+	// private key is only loaded from file if it's not in ssh-agent
+	var signer ssh.Signer
+	var privkey any
+	if (opts.prove || opts.renew) && !opts.oidc && !opts.device {
+		signer, privkey, err = keySigner(privkeyFile, pubkey)
+		if err != nil {
+			return err
+		}
+	}
+
+	var cert *ssh.Certificate
+	switch {
+	case opts.oidc || opts.device:
+		cert, err = loginCert(c, pubkeyFile, pubkeyBytes, opts.device)
+	case opts.renew:
+		cert, err = renewCert(c, pubkeyFile, signer)
+	default:
+		cert, err = requestCert(c, pubkeyFile, pubkeyBytes, opts.principal, opts.ttl, signer)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !opts.addToAgent {
+		return nil
+	}
+
+	if privkey == nil {
+		privkey, err = loadPrivateKey(privkeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	return addCertToAgent(privkeyFile, privkey, cert)
+}
+
+func defaultPubkeyFiles() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(home, ".ssh", "id_*.pub"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if !strings.HasSuffix(m, "-cert.pub") {
+			res = append(res, m)
+		}
+	}
+
+	return res, nil
+}
+
+// id_xxx.pub -> id_xxx-cert.pub
+func certFileOf(pubkeyFile string) string {
+	return strings.TrimSuffix(pubkeyFile, ".pub") + "-cert.pub"
+}
+
+// request certificate for the public key file and save it next to the key
+func requestCert(c *client.Client, pubkeyFile string, pubkey []byte, principal string, ttl time.Duration, signer ssh.Signer) (*ssh.Certificate, error) {
+	signed, err := c.Sign(&client.SignRequest{
+		Role:       "user",
+		PublicKey:  pubkey,
+		Principals: []string{principal},
+		TTL:        ttl,
+		Signer:     signer,
+	})
+
+	var pending *client.PendingApprovalError
+	if errors.As(err, &pending) {
+		fmt.Fprintf(os.Stderr, "request %s needs %d approval(s), waiting until %s\n",
+			pending.Request.Id, pending.Request.Required, pending.Request.ExpiresAt.Format(time.RFC3339))
+
+		signed, err = c.WaitApproval(pending.Request.Id, 5*time.Second)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return installCert(pubkeyFile, signed)
+}
+
+// renew the present certificate of the public key file by the certificate itself
+func renewCert(c *client.Client, pubkeyFile string, signer ssh.Signer) (*ssh.Certificate, error) {
+	certBytes, err := os.ReadFile(certFileOf(pubkeyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := c.Renew("user", certBytes, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return installCert(pubkeyFile, signed)
+}
+
+// login with OIDC, the certificate is bound to the identity logged in
+func loginCert(c *client.Client, pubkeyFile string, pubkey []byte, device bool) (*ssh.Certificate, error) {
+	var signed *model.Cert
+	var err error
+	if device {
+		signed, err = loginDevice(c, pubkey)
+	} else {
+		signed, err = loginBrowser(c, pubkey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return installCert(pubkeyFile, signed)
+}
+
+// authorization code flow, the browser is redirected back to a listener on loopback address
+func loginBrowser(c *client.Client, pubkey []byte) (*model.Cert, error) {
+	l, redirectURI, err := client.ListenLoopback()
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := c.StartOIDCLogin(pubkey, redirectURI)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "open %s to login\n", login.AuthURL)
+
+	code, err := client.ReceiveAuthCode(l, login)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ExchangeOIDCCode(login, code)
+}
+
+func loginDevice(c *client.Client, pubkey []byte) (*model.Cert, error) {
+	login, err := c.StartOIDCDevice(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	if login.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "open %s to login\n", login.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(os.Stderr, "open %s and enter code %s to login\n", login.VerificationURI, login.UserCode)
+	}
+
+	return c.WaitOIDCLogin(login)
+}
+
+// save signed certificate next to the public key file
+func installCert(pubkeyFile string, signed *model.Cert) (*ssh.Certificate, error) {
+	cert, err := utils.ParseSSHCert([]byte(signed.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	certFile := certFileOf(pubkeyFile)
+	err = utils.WriteFileAtomic(certFile, []byte(signed.Content+"\n"), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("%s: key id %q, principals %s, expires %s\n", certFile, cert.KeyId,
+		strings.Join(cert.ValidPrincipals, ","), time.Unix(int64(cert.ValidBefore), 0).Format(time.RFC3339))
+
+	return cert, nil
+}
+
+func loadPrivateKey(privkeyFile string) (any, error) {
+	privkeyBytes, err := os.ReadFile(privkeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	privkey, err := ssh.ParseRawPrivateKey(privkeyBytes)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return privkey, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter passphrase for %s: ", privkeyFile)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParseRawPrivateKeyWithPassphrase(privkeyBytes, passphrase)
+}
+
+// signer of the key from ssh-agent, or from the key file if it's not in agent
+func keySigner(privkeyFile string, pubkey ssh.PublicKey) (ssh.Signer, any, error) {
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err == nil {
+			signers, err := agent.NewClient(conn).Signers()
+			if err == nil {
+				for _, s := range signers {
+					if bytes.Equal(s.PublicKey().Marshal(), pubkey.Marshal()) {
+						// agent connection is left open for the signer till exit
+						return s, nil, nil
+					}
+				}
+			}
+			conn.Close()
+		}
+	}
+
+	privkey, err := loadPrivateKey(privkeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, err := ssh.NewSignerFromKey(privkey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return signer, privkey, nil
+}
+
+// load key and certificate to ssh-agent, the key is dropped once certificate expired
+func addCertToAgent(privkeyFile string, privkey any, cert *ssh.Certificate) error {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return errNoAgent
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lifetime := time.Until(time.Unix(int64(cert.ValidBefore), 0))
+	if lifetime <= 0 {
+		return fmt.Errorf("certificate %q already expired", cert.KeyId)
+	}
+
+	err = agent.NewClient(conn).Add(agent.AddedKey{
+		PrivateKey:   privkey,
+		Certificate:  cert,
+		Comment:      cert.KeyId,
+		LifetimeSecs: uint32(lifetime / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: added to agent for %s\n", privkeyFile, lifetime.Round(time.Second))
+
+	return nil
+}
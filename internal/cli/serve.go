@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/grpcapi"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi"
+	"github.com/0w0mewo/ssh_cert_ca/internal/sshapi"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "serve",
+		Usage: "start the CA server (default)",
+		Run:   serve,
+	})
+}
+
+func serve(args []string) error {
+	var err error
+	config.Cfg, err = config.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	svr := restapi.NewApiServer()
+	go svr.Start(config.Cfg.ListenTo)
+
+	var gsvr *grpcapi.GrpcServer
+	if config.Cfg.GrpcListenTo != "" {
+		gsvr = grpcapi.NewGrpcServer()
+		go gsvr.Start(config.Cfg.GrpcListenTo)
+	}
+
+	var ssvr *sshapi.SshServer
+	if config.Cfg.SSHServer != nil {
+		ssvr, err = sshapi.NewSshServer(config.Cfg.SSHServer)
+		if err != nil {
+			return err
+		}
+		go ssvr.Start(config.Cfg.SSHServer.ListenTo)
+	}
+
+	<-utils.WaitForSignal()
+	if ssvr != nil {
+		ssvr.Close()
+	}
+	if gsvr != nil {
+		gsvr.Close()
+	}
+	svr.Close()
+	app.Get().Close()
+
+	return nil
+}
@@ -5,13 +5,40 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/0w0mewo/ssh_cert_ca/pkg/identity"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
 )
 
 var Cfg *Config
 
+// file the config is loaded from, changes made at runtime are saved to it
+var cfgFile string
+
 type CAConfig struct {
 	PrivateKeyPath string `json:"priva_key_path"`
+	// host CA only, connect to the host and check it presents the host key before signing
+	VerifyHostKey bool   `json:"verify_host_key,omitempty"`
+	VerifyPort    int    `json:"verify_port,omitempty"`
+	VerifyTimeout uint64 `json:"verify_timeout,omitempty"` // seconds
+	// require caller to sign a nonce issued by /v1/cas/:role/challenges with the key to be signed
+	RequireSignedNonce bool `json:"require_signed_nonce,omitempty"`
+	// go template of key ids, e.g. {{.Requester}}@{{.ClientIP}}:{{join .Principals ","}}:{{.Timestamp.Unix}}:{{.Random}}
+	// variables: Requester, Identity, Principals, Role, Serial, Timestamp, ClientIP, Random. uuid if it's empty
+	KeyIdTemplate string `json:"keyid_template,omitempty"`
+	// hold back sensitive requests until approved
+	Approval *ApprovalConfig `json:"approval,omitempty"`
+	// retired CA public keys in authorized_keys format, certificates signed by them are still recognized on verification
+	PreviousPublicKeys []string `json:"previous_public_keys,omitempty"`
+}
+
+// requests for sensitive principals or lifetime longer than max_ttl need approvals
+// from tokens with approve scope, limited to approvers by token id if it's set
+type ApprovalConfig struct {
+	SensitivePrincipals []string `json:"sensitive_principals"`
+	MaxTTL              uint64   `json:"max_ttl"` // seconds, 0 means any
+	Required            int      `json:"required"`
+	Approvers           []string `json:"approvers,omitempty"`
+	Expiry              uint64   `json:"expiry"` // seconds before pending requests expire
 }
 
 type DBConfig struct {
@@ -19,15 +46,134 @@ type DBConfig struct {
 	DSN    string `json:"dsn"`
 }
 
+// paths and patterns used to render sshd_config and known_hosts snippets
+type BootstrapConfig struct {
+	HostDomains              []string `json:"host_domains"`
+	TrustedUserCAKeysPath    string   `json:"trusted_user_ca_keys_path"`
+	RevokedKeysPath          string   `json:"revoked_keys_path"`
+	AuthorizedPrincipalsFile string   `json:"authorized_principals_file"`
+}
+
+// OIDC login for user certificates, principals are derived from ID token claims
+type OIDCConfig struct {
+	Issuer          string   `json:"issuer"`
+	ClientID        string   `json:"client_id"`
+	ClientSecret    string   `json:"client_secret"`
+	Scopes          []string `json:"scopes"`
+	PrincipalClaims []string `json:"principal_claims"` // e.g. "email:localpart", "groups"
+	TTL             uint64   `json:"ttl"`              // seconds
+	// domains of emails trusted as identity and principals, only if the provider verified them
+	EmailDomains []string `json:"email_domains,omitempty"`
+}
+
+// map directory groups of requester identity to principals and extensions
+type LDAPConfig struct {
+	URL                string `json:"url"` // ldap://, ldaps://
+	BindDN             string `json:"bind_dn"`
+	BindPassword       string `json:"bind_password"`
+	StartTLS           bool   `json:"start_tls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	UserBaseDN         string `json:"user_base_dn"`
+	UserFilter         string `json:"user_filter"` // e.g. (uid=%s)
+	GroupBaseDN        string `json:"group_base_dn"`
+	GroupFilter        string `json:"group_filter"` // e.g. (member=%s)
+	GroupAttr          string `json:"group_attr"`   // e.g. cn
+	CacheTTL           uint64 `json:"cache_ttl"`    // seconds
+
+	Groups map[string]*identity.GroupMapping `json:"groups"`
+}
+
+// requests with a valid token and web UI assets are not counted
+type RateLimitConfig struct {
+	Max    int    `json:"max"`
+	Window uint64 `json:"window"` // seconds
+}
+
+// emergency user certificates for a fixed principal, issued without approval
+type BreakGlassConfig struct {
+	Principal string `json:"principal"`
+	TTL       uint64 `json:"ttl"`      // seconds, default 15 minutes
+	Cooldown  uint64 `json:"cooldown"` // seconds between two break-glass certificates, 0 means none
+}
+
+// ssh front-end for certificate requests, e.g. ssh -p 2222 ca.example.com sign < ~/.ssh/id_ed25519.pub
+type SSHServerConfig struct {
+	ListenTo    string `json:"listen_to"`
+	HostKeyPath string `json:"host_key_path"` // generated if it's not exist
+	// keys in authorized_keys format, scopes="sign,revoke" option sets scopes of the key, sign and read by default
+	AuthorizedKeysPath string `json:"authorized_keys_path,omitempty"`
+	// scopes of users logged in with a user certificate of this CA, disabled if it's empty
+	CertScopes string `json:"cert_scopes,omitempty"`
+	// ask for an API token by keyboard-interactive
+	KeyboardInteractive bool `json:"keyboard_interactive,omitempty"`
+}
+
+// Vault SSH secrets engine compatible endpoints under /v1/ssh, roles are keyed by name
+type VaultConfig struct {
+	Roles map[string]*VaultRole `json:"roles"`
+}
+
+// role of Vault SSH secrets engine, in its field names. only key_type ca is supported
+type VaultRole struct {
+	KeyType                string            `json:"key_type"`
+	AllowUserCertificates  bool              `json:"allow_user_certificates"`
+	AllowHostCertificates  bool              `json:"allow_host_certificates"`
+	DefaultUser            string            `json:"default_user,omitempty"`
+	AllowedUsers           string            `json:"allowed_users,omitempty"`   // comma separated, * for any
+	AllowedDomains         string            `json:"allowed_domains,omitempty"` // comma separated, * for any
+	AllowBareDomains       bool              `json:"allow_bare_domains,omitempty"`
+	AllowSubdomains        bool              `json:"allow_subdomains,omitempty"`
+	AllowUserKeyIds        bool              `json:"allow_user_key_ids,omitempty"`
+	TTL                    uint64            `json:"ttl,omitempty"`     // seconds
+	MaxTTL                 uint64            `json:"max_ttl,omitempty"` // seconds, 0 means any
+	DefaultExtensions      map[string]string `json:"default_extensions,omitempty"`
+	AllowedExtensions      string            `json:"allowed_extensions,omitempty"` // comma separated, * for any
+	DefaultCriticalOptions map[string]string `json:"default_critical_options,omitempty"`
+	AllowedCriticalOptions string            `json:"allowed_critical_options,omitempty"` // comma separated, * for any
+}
+
 type Config struct {
-	HostCA   *CAConfig `json:"host_ca"`
-	UserCA   *CAConfig `json:"user_ca"`
-	ListenTo string    `json:"listen_to"`
-	AuthKey  string    `json:"auth_key"`
-	DBconfig *DBConfig `json:"db"`
+	HostCA    *CAConfig        `json:"host_ca"`
+	UserCA    *CAConfig        `json:"user_ca"`
+	ListenTo  string           `json:"listen_to"`
+	AuthKey   string           `json:"auth_key"`
+	DBconfig  *DBConfig        `json:"db"`
+	Bootstrap *BootstrapConfig `json:"bootstrap"`
+	// seconds before an unused proof of possession nonce expires
+	ChallengeTTL uint64 `json:"challenge_ttl,omitempty"`
+	// limit of requests per client IP, 30 per minute if it's not set
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+	// OIDC login is disabled if it's not set
+	OIDC *OIDCConfig `json:"oidc,omitempty"`
+	// identity to principals resolution is disabled if it's not set
+	LDAP *LDAPConfig `json:"ldap,omitempty"`
+	// break-glass endpoint is disabled if it's not set
+	BreakGlass *BreakGlassConfig `json:"break_glass,omitempty"`
+	// command run with sh -c on high priority events, e.g. break-glass issuance
+	AlertHook string `json:"alert_hook,omitempty"`
+	// address of the gRPC API, it's disabled if it's not set
+	GrpcListenTo string `json:"grpc_listen_to,omitempty"`
+	// ssh front-end is disabled if it's not set
+	SSHServer *SSHServerConfig `json:"ssh_server,omitempty"`
+	// Vault compatible endpoints are disabled if it's not set
+	Vault *VaultConfig `json:"vault,omitempty"`
+	// respond errors with HTTP 200 and {"code": -1, "errMsg": ...} as older versions,
+	// instead of proper status and application/problem+json body
+	LegacyErrors bool `json:"legacy_errors,omitempty"`
+}
+
+func defaultBootstrapConfig() *BootstrapConfig {
+	return &BootstrapConfig{
+		HostDomains:              []string{"*"},
+		TrustedUserCAKeysPath:    "/etc/ssh/trusted_user_ca_keys",
+		RevokedKeysPath:          "/etc/ssh/revoked_keys",
+		AuthorizedPrincipalsFile: "/etc/ssh/auth_principals/%u",
+	}
 }
 
 func LoadConfig(fname string) (cfg *Config, err error) {
+	cfgFile = fname
+
 	// generate default config file if it's not exist
 	if !utils.IsFileExist(fname) {
 		cfg = &Config{
@@ -43,6 +189,7 @@ func LoadConfig(fname string) (cfg *Config, err error) {
 				Driver: "sqlite3",
 				DSN:    "file:certs.db?mode=rwc&cache=shared&_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=8000",
 			},
+			Bootstrap: defaultBootstrapConfig(),
 		}
 
 		cfgfileBytes, err := json.MarshalIndent(cfg, "", " ")
@@ -72,6 +219,21 @@ func LoadConfig(fname string) (cfg *Config, err error) {
 		return
 	}
 
+	// config files generated by older version
+	if cfg.Bootstrap == nil {
+		cfg.Bootstrap = defaultBootstrapConfig()
+	}
+
 	return
 
 }
+
+// write the config back to the file it's loaded from
+func Save(cfg *Config) error {
+	cfgfileBytes, err := json.MarshalIndent(cfg, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(cfgFile, cfgfileBytes, 0644)
+}
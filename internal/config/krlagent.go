@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+)
+
+// config of KRL distribution agent
+type KRLAgentConfig struct {
+	Server          string `json:"server"`
+	Token           string `json:"token"`
+	Role            string `json:"role"`
+	CAPublicKey     string `json:"ca_pubkey"` // trusted CA public key in authorized_keys format
+	RevokedKeysPath string `json:"revoked_keys_path"`
+	PollInterval    uint64 `json:"poll_interval"` // seconds
+	StaleAfter      uint64 `json:"stale_after"`   // seconds without successful update before alerting
+	AlertHook       string `json:"alert_hook"`
+}
+
+func LoadKRLAgentConfig(fname string) (cfg *KRLAgentConfig, err error) {
+	// generate default config file if it's not exist
+	if !utils.IsFileExist(fname) {
+		cfg = &KRLAgentConfig{
+			Server:          "http://127.0.0.1:8077",
+			Role:            "user",
+			RevokedKeysPath: "/etc/ssh/revoked_keys",
+			PollInterval:    60,
+			StaleAfter:      3600,
+		}
+
+		cfgfileBytes, err := json.MarshalIndent(cfg, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		err = ioutil.WriteFile(fname, cfgfileBytes, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		return cfg, nil
+
+	}
+
+	cf, err := os.Open(fname)
+	if err != nil {
+		return
+	}
+	defer cf.Close()
+
+	cfg = &KRLAgentConfig{}
+
+	err = json.NewDecoder(cf).Decode(cfg)
+	if err != nil {
+		return
+	}
+
+	return
+
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+)
+
+type RenewKeyConfig struct {
+	PublicKeyPath string   `json:"pubkey_path"`
+	CertPath      string   `json:"cert_path"` // default to <pubkey>-cert.pub
+	Principals    []string `json:"principals"`
+	TTL           uint64   `json:"ttl"` // seconds
+}
+
+// config of host certificate renewal daemon
+type RenewConfig struct {
+	Server        string            `json:"server"`
+	Token         string            `json:"token"`
+	CheckInterval uint64            `json:"check_interval"` // seconds
+	RenewFraction float64           `json:"renew_fraction"` // renew once the fraction of validity elapsed
+	ReloadHook    string            `json:"reload_hook"`
+	MaxBackoff    uint64            `json:"max_backoff"`     // seconds
+	SignNonce     bool              `json:"sign_nonce"`      // prove possession of host keys by signing challenge
+	RenewWithCert bool              `json:"renew_with_cert"` // renew by present certificate instead of token while it's valid
+	Keys          []*RenewKeyConfig `json:"keys"`
+}
+
+func LoadRenewConfig(fname string) (cfg *RenewConfig, err error) {
+	// generate default config file if it's not exist
+	if !utils.IsFileExist(fname) {
+		cfg = &RenewConfig{
+			Server:        "http://127.0.0.1:8077",
+			CheckInterval: 60,
+			RenewFraction: 0.5,
+			ReloadHook:    "systemctl reload sshd",
+			MaxBackoff:    3600,
+			Keys: []*RenewKeyConfig{
+				{
+					PublicKeyPath: "/etc/ssh/ssh_host_ed25519_key.pub",
+					Principals:    []string{"localhost"},
+					TTL:           24 * 3600,
+				},
+			},
+		}
+
+		cfgfileBytes, err := json.MarshalIndent(cfg, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		err = ioutil.WriteFile(fname, cfgfileBytes, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		return cfg, nil
+
+	}
+
+	cf, err := os.Open(fname)
+	if err != nil {
+		return
+	}
+	defer cf.Close()
+
+	cfg = &RenewConfig{}
+
+	err = json.NewDecoder(cf).Decode(cfg)
+	if err != nil {
+		return
+	}
+
+	return
+
+}
@@ -0,0 +1,110 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const serviceName = "/sshca.v1.CertificateAuthority/"
+
+// scope required by each method, methods not listed are refused
+var methodScopes = map[string]string{
+	serviceName + "CreateChallenge": model.ScopeSign,
+	serviceName + "Sign":            model.ScopeSign,
+	serviceName + "Revoke":          model.ScopeRevoke,
+	serviceName + "GetPublicKey":    model.ScopeRead,
+	serviceName + "ListCerts":       model.ScopeRead,
+	serviceName + "WatchKRL":        model.ScopeRead,
+}
+
+type tokenCtxKey struct{}
+
+// bearer token auth of the REST API, taken from authorization metadata
+type authInterceptor struct {
+	auth *auth.Authenticator
+}
+
+func (ai *authInterceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := ai.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (ai *authInterceptor) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := ai.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (ai *authInterceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	secret := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			secret = strings.TrimPrefix(v[0], "Bearer ")
+		}
+	}
+
+	t, err := ai.auth.Authenticate(secret)
+	if err != nil {
+		log.Printf("auth fail from %s: %s", peerIP(ctx), method)
+
+		if !errors.Is(err, auth.ErrInvalidAuthKey) {
+			err = auth.ErrInvalidAuthKey.Wrap(err)
+		}
+
+		return nil, err
+	}
+
+	scope, ok := methodScopes[method]
+	if !ok || !t.HasScope(scope) {
+		return nil, auth.ErrInsufficientScope
+	}
+
+	log.Printf("auth success from %s: %s", peerIP(ctx), method)
+
+	return context.WithValue(ctx, tokenCtxKey{}, t), nil
+}
+
+// stream with the context carrying authenticated token
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
+
+func tokenFromCtx(ctx context.Context) *model.Token {
+	t, _ := ctx.Value(tokenCtxKey{}).(*model.Token)
+	return t
+}
+
+// address of the caller without port
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	addr := p.Addr.String()
+	if i := strings.LastIndex(addr, ":"); i > 0 {
+		addr = strings.Trim(addr[:i], "[]")
+	}
+
+	return addr
+}
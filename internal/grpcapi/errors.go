@@ -0,0 +1,75 @@
+package grpcapi
+
+import (
+	"context"
+	"log"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// domain of ErrorInfo details, reason is the code from the error catalogue
+const errorDomain = "ssh_cert_ca"
+
+var kindCodes = map[errs.Kind]codes.Code{
+	errs.Internal:         codes.Internal,
+	errs.Invalid:          codes.InvalidArgument,
+	errs.InvalidKey:       codes.InvalidArgument,
+	errs.Unauthorized:     codes.Unauthenticated,
+	errs.Forbidden:        codes.PermissionDenied,
+	errs.NotFound:         codes.NotFound,
+	errs.Conflict:         codes.FailedPrecondition,
+	errs.TooManyRequests:  codes.ResourceExhausted,
+	errs.UnsupportedMedia: codes.InvalidArgument,
+	errs.Unavailable:      codes.Unavailable,
+}
+
+func unaryErrInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, toStatusErr(info.FullMethod, err)
+	}
+
+	return resp, nil
+}
+
+func streamErrInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if err != nil {
+		return toStatusErr(info.FullMethod, err)
+	}
+
+	return nil
+}
+
+// status of the error by its kind, with its catalogue code as ErrorInfo reason
+func toStatusErr(method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code, ok := kindCodes[errs.KindOf(err)]
+	if !ok {
+		code = codes.Internal
+	}
+
+	// causes of internal errors are for the server log only
+	msg := err.Error()
+	if code == codes.Internal {
+		log.Printf("%s: %v", method, err)
+		msg = errs.Title(errs.CodeOf(err))
+	}
+
+	st, derr := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: errs.CodeOf(err),
+		Domain: errorDomain,
+	})
+	if derr != nil {
+		return status.Error(code, msg)
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,66 @@
+package grpcapi
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/pb"
+	"google.golang.org/grpc"
+)
+
+// GrpcServer serves the CA operations over gRPC, backed by the same services as the REST API
+type GrpcServer struct {
+	server *grpc.Server
+}
+
+func NewGrpcServer() *GrpcServer {
+	a := app.Get()
+	ai := &authInterceptor{auth: a.Auth}
+
+	gs := &GrpcServer{
+		server: grpc.NewServer(
+			// errors are converted last, so auth failures get proper codes as well
+			grpc.ChainUnaryInterceptor(unaryErrInterceptor, ai.unary),
+			grpc.ChainStreamInterceptor(streamErrInterceptor, ai.stream),
+		),
+	}
+
+	pb.RegisterCertificateAuthorityServer(gs.server, &caServer{app: a})
+
+	return gs
+}
+
+func (gs *GrpcServer) Start(address string) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Println("grpc server start at ", address)
+
+	err = gs.server.Serve(lis)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+func (gs *GrpcServer) Close() {
+	stopped := make(chan struct{})
+	go func() {
+		gs.server.GracefulStop()
+		close(stopped)
+	}()
+
+	// KRL watchers never hang up by themselves
+	select {
+	case <-stopped:
+	case <-time.After(4 * time.Second):
+		gs.server.Stop()
+	}
+
+	log.Println("grpc server stop")
+}
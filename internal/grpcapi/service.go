@@ -0,0 +1,237 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/pb"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// lifetime of certificates requested without ttl, same as the REST API
+const defaultTTL = 24 * 365 * time.Hour
+
+type caServer struct {
+	pb.UnimplementedCertificateAuthorityServer
+
+	app *app.App
+}
+
+func (s *caServer) caOf(role pb.Role) (*service.SSHCertCAService, error) {
+	switch role {
+	case pb.Role_ROLE_USER:
+		return s.app.UserCA, nil
+	case pb.Role_ROLE_HOST:
+		return s.app.HostCA, nil
+	}
+
+	return nil, model.ErrUnsupportedCertType
+}
+
+func (s *caServer) CreateChallenge(ctx context.Context, req *pb.CreateChallengeRequest) (*pb.Challenge, error) {
+	_, err := s.caOf(req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey([]byte(req.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := s.app.Challenges.Issue(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Challenge{
+		Id:        ch.Id,
+		Nonce:     ch.Nonce,
+		ExpiresAt: timestamppb.New(ch.ExpiresAt),
+	}, nil
+}
+
+func (s *caServer) Sign(ctx context.Context, req *pb.SignRequest) (*pb.SignResponse, error) {
+	signer, err := s.caOf(req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts *ca.CertOptions
+	if req.Extensions != nil || req.CriticalOptions != nil {
+		opts = &ca.CertOptions{
+			Extensions:      req.Extensions,
+			CriticalOptions: req.CriticalOptions,
+		}
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey([]byte(req.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	// proof of possession
+	if requireSignedNonce(signer.Role()) {
+		sig, err := verify.ParseSignature(req.Signature)
+		if err != nil {
+			return nil, verify.ErrBadSignature
+		}
+
+		err = s.app.Challenges.Verify(req.Challenge, pubkey, sig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	sreq := &service.SignRequest{
+		PublicKey:   pubkey,
+		Requester:   tokenFromCtx(ctx).Name,
+		RequesterId: tokenFromCtx(ctx).Id,
+		ClientIP:    peerIP(ctx),
+		Principals:  req.Principals,
+		TTL:         ttl,
+		Options:     opts,
+	}
+
+	cert, err := signer.Issue(sreq)
+	if errors.Is(err, policy.ErrApprovalRequired) {
+		cr, err := s.app.Approvals.Submit(signer.Role(), sreq)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("request %s for %s by %s is pending approval", cr.Id, cr.Principals, cr.Requester)
+
+		return &pb.SignResponse{Result: &pb.SignResponse_Pending{Pending: &pb.PendingRequest{
+			Id:        cr.Id,
+			Required:  int32(cr.Required),
+			ExpiresAt: timestamppb.New(cr.ExpiresAt),
+		}}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.SignResponse{Result: &pb.SignResponse_Certificate{Certificate: toPbCert(&cert)}}, nil
+}
+
+func (s *caServer) Revoke(ctx context.Context, req *pb.RevokeRequest) (*pb.RevokeResponse, error) {
+	signer, err := s.caOf(req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = signer.Revoke(req.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeResponse{}, nil
+}
+
+func (s *caServer) GetPublicKey(ctx context.Context, req *pb.GetPublicKeyRequest) (*pb.GetPublicKeyResponse, error) {
+	signer, err := s.caOf(req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetPublicKeyResponse{PublicKey: signer.PublicKeyAsAuthKeyStr()}, nil
+}
+
+func (s *caServer) ListCerts(ctx context.Context, req *pb.ListCertsRequest) (*pb.ListCertsResponse, error) {
+	signer, err := s.caOf(req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := signer.ListCerts()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*pb.Certificate, 0, len(certs))
+	for _, c := range certs {
+		if c.Revoked && !req.IncludeRevoked {
+			continue
+		}
+
+		res = append(res, toPbCert(c))
+	}
+
+	return &pb.ListCertsResponse{Certificates: res}, nil
+}
+
+// push the present KRL unless the host has it, then every new one till the host hangs up
+func (s *caServer) WatchKRL(req *pb.WatchKRLRequest, stream pb.CertificateAuthority_WatchKRLServer) error {
+	signer, err := s.caOf(req.Role)
+	if err != nil {
+		return err
+	}
+
+	known := req.KnownVersion
+	for {
+		// subscribe before reading, so a change in between is not missed
+		changed := signer.KRLChanged()
+
+		krl, version := signer.GetPresentRevokedListWithVersion()
+		if version != known {
+			err := stream.Send(&pb.KRL{Version: version, Krl: krl})
+			if err != nil {
+				return err
+			}
+
+			known = version
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func requireSignedNonce(role model.RoleType) bool {
+	if role == model.CertTypeHost {
+		return config.Cfg.HostCA.RequireSignedNonce
+	}
+
+	return config.Cfg.UserCA.RequireSignedNonce
+}
+
+func toPbRole(role model.RoleType) pb.Role {
+	switch role {
+	case model.CerTypeUser:
+		return pb.Role_ROLE_USER
+	case model.CertTypeHost:
+		return pb.Role_ROLE_HOST
+	}
+
+	return pb.Role_ROLE_UNSPECIFIED
+}
+
+func toPbCert(c *model.Cert) *pb.Certificate {
+	return &pb.Certificate{
+		KeyId:       c.KeyId,
+		Role:        toPbRole(c.Type),
+		ValidAfter:  timestamppb.New(c.ValidStart),
+		ValidBefore: timestamppb.New(c.ValidEnd),
+		Content:     c.Content,
+		Revoked:     c.Revoked,
+	}
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// BreakGlass is the audit record of an emergency certificate
+type BreakGlass struct {
+	Id            string    `json:"id" db:"id"`
+	KeyId         string    `json:"cert_id" db:"keyid"`
+	Requester     string    `json:"requester" db:"requester"`
+	Principal     string    `json:"principal" db:"principal"`
+	Justification string    `json:"justification" db:"justification"`
+	Ticket        string    `json:"ticket" db:"ticket"`
+	ClientIP      string    `json:"client_ip" db:"client_ip"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	ValidEnd      time.Time `json:"valid_end" db:"valid_end"`
+}
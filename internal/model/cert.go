@@ -1,9 +1,10 @@
 package model
 
 import (
-	"errors"
 	"strings"
 	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
 )
 
 const (
@@ -13,7 +14,10 @@ const (
 
 type RoleType int
 
-var ErrUnsupportedCertType = errors.New("unsupported cert type")
+// width of the key id column of the store
+const MaxKeyIdLength = 512
+
+var ErrUnsupportedCertType = errs.New(errs.CodeUnsupportedRole, "unsupported cert type")
 
 type Cert struct {
 	KeyId      string    `json:"id" db:"keyid"`
@@ -22,6 +26,8 @@ type Cert struct {
 	ValidEnd   time.Time `json:"valid_end" db:"valid_end"`
 	Content    string    `json:"cert_content" db:"content"`
 	Revoked    bool      `json:"revoked" db:"revoked"`
+	// emergency certificate, never renewed
+	BreakGlass bool `json:"break_glass,omitempty" db:"break_glass"`
 }
 
 func ParseCertType(certType string) (RoleType, error) {
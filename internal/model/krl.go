@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// ImportedKRL is a KRL of an external CA, merged into every KRL the CA generates
+type ImportedKRL struct {
+	Id         string    `json:"id" db:"id"` // sha256 of content
+	Type       RoleType  `json:"type" db:"type"`
+	Content    []byte    `json:"-" db:"content"`
+	ImportedAt time.Time `json:"imported_at" db:"imported_at"`
+}
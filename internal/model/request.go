@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// status of certificate requests waiting for approval
+const (
+	RequestPending = "pending"
+	RequestIssued  = "issued"
+	RequestDenied  = "denied"
+	RequestExpired = "expired"
+)
+
+// CertRequest is a signing request held back until enough approvers agreed on it
+type CertRequest struct {
+	Id        string   `json:"id" db:"id"`
+	Type      RoleType `json:"type" db:"type"`
+	Requester string   `json:"requester" db:"requester"`
+	// token id of the requester, the only one the certificate is handed to
+	RequesterId string `json:"requester_id" db:"requester_id"`
+	ClientIP    string `json:"client_ip" db:"client_ip"`
+	Identity    string `json:"identity,omitempty" db:"identity"`
+	PublicKey   string `json:"pubkey" db:"pubkey"`         // authorized_keys format
+	Principals  string `json:"principals" db:"principals"` // comma separated
+	TTL         uint64 `json:"ttl" db:"ttl"`               // seconds
+	Serial      uint64 `json:"serial" db:"serial"`
+	Options     string `json:"options,omitempty" db:"options"`
+	Status      string `json:"status" db:"status"`
+	Required    int    `json:"required" db:"required"`
+	// token ids of approvers, kept in a table of their own
+	Approvers []string `json:"approvers" db:"-"`
+	DeniedBy  string   `json:"denied_by,omitempty" db:"denied_by"`
+	// key id of the certificate, rendered when it's issued unless requested explicitly
+	CertKeyId string    `json:"cert_id,omitempty" db:"cert_keyid"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+func (r CertRequest) ApprovedBy(approverId string) bool {
+	for _, a := range r.Approvers {
+		if a == approverId {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Revocation records why and by whom a certificate is revoked
+type Revocation struct {
+	KeyId     string    `json:"cert_id" db:"keyid"`
+	Reason    string    `json:"reason" db:"reason"`
+	RevokedBy string    `json:"revoked_by" db:"revoked_by"`
+	RevokedAt time.Time `json:"revoked_at" db:"revoked_at"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	ScopeSign   = "sign"
+	ScopeRevoke = "revoke"
+	ScopeRead   = "read"
+	ScopeAdmin  = "admin"
+	// approve or deny certificate requests held for approval
+	ScopeApprove = "approve"
+	// request emergency certificates
+	ScopeBreakGlass = "breakglass"
+	DefaultScope    = ScopeSign + "," + ScopeRead
+)
+
+// every scope a token may be granted
+var Scopes = []string{ScopeSign, ScopeRevoke, ScopeRead, ScopeAdmin, ScopeApprove, ScopeBreakGlass}
+
+// check each of comma separated scopes is known
+func ValidScopes(scopes string) bool {
+	for _, s := range strings.Split(scopes, ",") {
+		known := false
+		for _, k := range Scopes {
+			if strings.TrimSpace(s) == k {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Token struct {
+	Id        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Hash      string    `json:"-" db:"hash"`
+	Scopes    string    `json:"scopes" db:"scopes"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// token without expire time never expires
+func (t Token) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && t.ExpiresAt.Before(time.Now())
+}
+
+// admin scope implies all other scopes
+func (t Token) HasScope(scope string) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		s = strings.TrimSpace(s)
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
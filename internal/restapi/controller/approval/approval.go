@@ -0,0 +1,49 @@
+package approval
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router lets approvers decide on requests held back by the sign endpoint,
+// and requesters collect certificates once approved
+type Router struct {
+	app *app.App
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	v1 := attchedTo.Group("/v1/requests")
+
+	v1.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		v1.Get("/", auth.RequireScope(model.ScopeApprove), r.List)
+		v1.Get("/:id", auth.RequireScope(model.ScopeSign), r.Get)
+		v1.Post("/:id/approve", auth.RequireScope(model.ScopeApprove), r.Approve)
+		v1.Post("/:id/deny", auth.RequireScope(model.ScopeApprove), r.Deny)
+	}
+
+	grp := attchedTo.Group("/ca/requests")
+
+	grp.Use(r.app.Auth.Middleware())
+
+	// legacy routes, kept until sunset
+	{
+		grp.Get("/", controller.Deprecated("/v1/requests/"), auth.RequireScope(model.ScopeApprove), r.List)
+		grp.Get("/:id", controller.Deprecated("/v1/requests/:id"), auth.RequireScope(model.ScopeSign), r.Get)
+		grp.Post("/:id/approve", controller.Deprecated("/v1/requests/:id/approve"), auth.RequireScope(model.ScopeApprove), r.Approve)
+		grp.Post("/:id/deny", controller.Deprecated("/v1/requests/:id/deny"), auth.RequireScope(model.ScopeApprove), r.Deny)
+	}
+}
+
+func (r *Router) Close() {}
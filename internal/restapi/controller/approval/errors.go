@@ -0,0 +1,5 @@
+package approval
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errNotRequester = errs.New(errs.CodeInsufficientScope, "request is made by another token")
@@ -0,0 +1,92 @@
+package approval
+
+import (
+	"log"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+// list requests, pending ones by default
+func (r *Router) List(c *fiber.Ctx) error {
+	req := ListRequest{Status: model.RequestPending}
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	// all of them
+	if req.Status == "all" {
+		req.Status = ""
+	}
+
+	reqs, err := r.app.Approvals.List(req.Status)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(reqs))
+}
+
+// status of the request, with the certificate once issued.
+// only visible to the requester and approvers, the certificate is handed to the requester token only
+func (r *Router) Get(c *fiber.Ctx) error {
+	var req DecisionRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	t := auth.TokenFromCtx(c)
+
+	cr, cert, err := r.app.Approvals.Get(req.Id, t.Id)
+	if err != nil {
+		return err
+	}
+
+	if cr.RequesterId != t.Id && !t.HasScope(model.ScopeApprove) {
+		return errNotRequester
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(&RequestResp{CertRequest: cr, Cert: cert}))
+}
+
+func (r *Router) Approve(c *fiber.Ctx) error {
+	var req DecisionRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	approver := auth.TokenFromCtx(c)
+
+	cr, err := r.app.Approvals.Approve(req.Id, approver.Id)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("request %s approved by %s (%s) (%d/%d), status %s", cr.Id, approver.Name, approver.Id, len(cr.Approvers), cr.Required, cr.Status)
+
+	return c.JSON(controller.NewCommonRespWithData(cr))
+}
+
+func (r *Router) Deny(c *fiber.Ctx) error {
+	var req DecisionRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	approver := auth.TokenFromCtx(c)
+
+	cr, err := r.app.Approvals.Deny(req.Id, approver.Id)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("request %s denied by %s (%s)", cr.Id, approver.Name, approver.Id)
+
+	return c.JSON(controller.NewCommonRespWithData(cr))
+}
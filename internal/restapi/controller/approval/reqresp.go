@@ -0,0 +1,17 @@
+package approval
+
+import "github.com/0w0mewo/ssh_cert_ca/internal/model"
+
+type ListRequest struct {
+	Status string `query:"status"`
+}
+
+type DecisionRequest struct {
+	Id string `params:"id"`
+}
+
+// request and its certificate once issued
+type RequestResp struct {
+	*model.CertRequest
+	Cert *model.Cert `json:"cert,omitempty"`
+}
@@ -0,0 +1,33 @@
+package backups
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router makes backup archives of the running CA, as the backup subcommand does offline
+type Router struct {
+	app *app.App
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	v1 := attchedTo.Group("/v1/backups")
+
+	v1.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		v1.Post("/", auth.RequireScope(model.ScopeAdmin), r.Create)
+	}
+}
+
+func (r *Router) Close() {}
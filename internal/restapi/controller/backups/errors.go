@@ -0,0 +1,5 @@
+package backups
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errUnencryptedKeys = errs.New(errs.CodeInvalidInput, "private keys are only exported in archives encrypted with a passphrase")
@@ -0,0 +1,47 @@
+package backups
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/backup"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+// archive of config, CA keys, DB snapshot and KRLs, restored offline with the restore subcommand
+func (r *Router) Create(c *fiber.Ctx) error {
+	var req CreateRequest
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(&req)
+		if err != nil {
+			return controller.ErrBadRequest.Wrap(err)
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	archive, m, err := backup.Create(r.app, config.Cfg, &backup.Options{
+		PrivateKeys: req.PrivateKeys,
+		Passphrase:  []byte(req.Passphrase),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("backup made by %s, private keys: %t", auth.TokenFromCtx(c).Name, m.PrivateKeys)
+
+	fname := fmt.Sprintf("ssh_cert_ca-%s.tar.gz", m.CreatedAt.Format("20060102-150405"))
+	if m.Encrypted {
+		fname += ".enc"
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fname))
+
+	return c.Send(archive)
+}
@@ -0,0 +1,14 @@
+package backups
+
+type CreateRequest struct {
+	PrivateKeys bool   `json:"private_keys"`
+	Passphrase  string `json:"passphrase"` // archive is encrypted if it's set
+}
+
+func (cr *CreateRequest) Validate() error {
+	if cr.PrivateKeys && cr.Passphrase == "" {
+		return errUnencryptedKeys
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package bootstrap
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router renders ready-to-use sshd_config and known_hosts artifacts for host bootstrapping
+type Router struct {
+	app *app.App
+	cfg *config.BootstrapConfig
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+	r.cfg = config.Cfg.Bootstrap
+
+	v1 := attchedTo.Group("/v1/bootstrap")
+
+	v1.Use(r.app.Auth.Middleware(), auth.RequireScope(model.ScopeRead))
+
+	// routes
+	{
+		v1.Get("/trusted-user-ca-keys", r.TrustedUserCAKeys)
+		v1.Get("/sshd-config", r.SshdConfig)
+		v1.Get("/known-hosts", r.KnownHosts)
+	}
+
+	grp := attchedTo.Group("/ca/bootstrap")
+
+	grp.Use(r.app.Auth.Middleware(), auth.RequireScope(model.ScopeRead))
+
+	// legacy routes, kept until sunset
+	{
+		grp.Get("/trusted-user-ca-keys", controller.Deprecated("/v1/bootstrap/trusted-user-ca-keys"), r.TrustedUserCAKeys)
+		grp.Get("/sshd-config", controller.Deprecated("/v1/bootstrap/sshd-config"), r.SshdConfig)
+		grp.Get("/known-hosts", controller.Deprecated("/v1/bootstrap/known-hosts"), r.KnownHosts)
+	}
+}
+
+func (r *Router) Close() {}
@@ -0,0 +1,6 @@
+package bootstrap
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errInvalidHostKey = errs.New(errs.CodeInvalidInput, "invalid host key path, expect absolute paths without whitespace")
+var errInvalidDomain = errs.New(errs.CodeInvalidInput, "invalid domain pattern, expect host names with wildcards")
@@ -0,0 +1,48 @@
+package bootstrap
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+// content of TrustedUserCAKeys file
+func (r *Router) TrustedUserCAKeys(c *fiber.Ctx) error {
+	return c.SendString(RenderTrustedUserCAKeys(r.app.UserCA.PublicKeyAsAuthKeyStr()))
+}
+
+// sshd_config.d drop-in for the host keys given by query
+func (r *Router) SshdConfig(c *fiber.Ctx) error {
+	var req SshdConfigRequest
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return err
+	}
+
+	return c.SendString(RenderSshdConfig(r.cfg, req.SplitedHostKeys()))
+}
+
+// @cert-authority line of host CA for known_hosts
+func (r *Router) KnownHosts(c *fiber.Ctx) error {
+	var req KnownHostsRequest
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return err
+	}
+
+	domains := r.cfg.HostDomains
+	if req.Domains != "" {
+		domains = req.SplitedDomains()
+	}
+
+	return c.SendString(RenderKnownHosts(domains, r.app.HostCA.PublicKeyAsAuthKeyStr()))
+}
@@ -0,0 +1,53 @@
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+)
+
+const header = "# generated by ssh cert ca\n"
+
+func RenderTrustedUserCAKeys(userCAPubkey string) string {
+	return userCAPubkey + "\n"
+}
+
+// host certificates are expected to be next to the host keys, as <host key>-cert.pub
+func RenderSshdConfig(cfg *config.BootstrapConfig, hostKeys []string) string {
+	var sb strings.Builder
+
+	sb.WriteString(header)
+	for _, hk := range hostKeys {
+		hk = strings.TrimSuffix(strings.TrimSpace(hk), ".pub")
+		if hk == "" {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "HostCertificate %s-cert.pub\n", hk)
+	}
+
+	fmt.Fprintf(&sb, "TrustedUserCAKeys %s\n", cfg.TrustedUserCAKeysPath)
+	fmt.Fprintf(&sb, "RevokedKeys %s\n", cfg.RevokedKeysPath)
+	if cfg.AuthorizedPrincipalsFile != "" {
+		fmt.Fprintf(&sb, "AuthorizedPrincipalsFile %s\n", cfg.AuthorizedPrincipalsFile)
+	}
+
+	return sb.String()
+}
+
+func RenderKnownHosts(domains []string, hostCAPubkey string) string {
+	patterns := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimSpace(d)
+		if d != "" {
+			patterns = append(patterns, d)
+		}
+	}
+
+	if len(patterns) == 0 {
+		patterns = append(patterns, "*")
+	}
+
+	return fmt.Sprintf("@cert-authority %s %s\n", strings.Join(patterns, ","), hostCAPubkey)
+}
@@ -0,0 +1,70 @@
+package bootstrap
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+type SshdConfigRequest struct {
+	HostKeys string `query:"hostkeys"`
+}
+
+func (r SshdConfigRequest) SplitedHostKeys() []string {
+	if r.HostKeys == "" {
+		return []string{"/etc/ssh/ssh_host_ed25519_key"}
+	}
+
+	return strings.Split(r.HostKeys, ",")
+}
+
+// paths are rendered into sshd_config lines, so nothing may break out of them
+func (r SshdConfigRequest) Validate() error {
+	for _, hk := range r.SplitedHostKeys() {
+		if hk == "" {
+			continue
+		}
+
+		if !path.IsAbs(hk) || strings.IndexFunc(hk, isSpaceOrControl) >= 0 {
+			return errInvalidHostKey
+		}
+	}
+
+	return nil
+}
+
+type KnownHostsRequest struct {
+	Domains string `query:"domains"`
+}
+
+func (r KnownHostsRequest) SplitedDomains() []string {
+	return strings.Split(r.Domains, ",")
+}
+
+// patterns of known_hosts, e.g. *.example.com, !bastion.example.com, [host]:2222
+func (r KnownHostsRequest) Validate() error {
+	for _, d := range r.SplitedDomains() {
+		if d == "" {
+			continue
+		}
+
+		if strings.IndexFunc(d, isNotPatternChar) >= 0 {
+			return errInvalidDomain
+		}
+	}
+
+	return nil
+}
+
+func isSpaceOrControl(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+func isNotPatternChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+
+	return !strings.ContainsRune(".-_*?![]:", r)
+}
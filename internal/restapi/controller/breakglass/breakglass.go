@@ -0,0 +1,48 @@
+package breakglass
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router issues emergency certificates, bypassing approval
+type Router struct {
+	app *app.App
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	if r.app.BreakGlass == nil {
+		return
+	}
+
+	v1 := attchedTo.Group("/v1/breakglass")
+
+	v1.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		v1.Post("/", auth.RequireScope(model.ScopeBreakGlass), r.Issue)
+		v1.Get("/", auth.RequireScope(model.ScopeAdmin), r.Records)
+	}
+
+	grp := attchedTo.Group("/ca/breakglass")
+
+	grp.Use(r.app.Auth.Middleware())
+
+	// legacy routes, kept until sunset
+	{
+		grp.Post("/", controller.Deprecated("/v1/breakglass/"), auth.RequireScope(model.ScopeBreakGlass), r.Issue)
+		grp.Get("/", controller.Deprecated("/v1/breakglass/"), auth.RequireScope(model.ScopeAdmin), r.Records)
+	}
+}
+
+func (r *Router) Close() {}
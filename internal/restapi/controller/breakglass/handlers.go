@@ -0,0 +1,39 @@
+package breakglass
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// issue an emergency certificate for the configured break-glass principal
+func (r *Router) Issue(c *fiber.Ctx) error {
+	var req IssueRequest
+	err := c.BodyParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey([]byte(req.PublicKey))
+	if err != nil {
+		return err
+	}
+
+	cert, rec, err := r.app.BreakGlass.Issue(pubkey, auth.TokenFromCtx(c).Name, req.Justification, req.Ticket, c.IP())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(&IssueResp{Cert: cert, Record: rec}))
+}
+
+// audit records of every break-glass certificate
+func (r *Router) Records(c *fiber.Ctx) error {
+	recs, err := r.app.BreakGlass.Records()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(recs))
+}
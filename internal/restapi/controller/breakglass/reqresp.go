@@ -0,0 +1,14 @@
+package breakglass
+
+import "github.com/0w0mewo/ssh_cert_ca/internal/model"
+
+type IssueRequest struct {
+	PublicKey     string `json:"pubkey"` // authorized_keys format
+	Justification string `json:"justification"`
+	Ticket        string `json:"ticket"`
+}
+
+type IssueResp struct {
+	model.Cert
+	Record *model.BreakGlass `json:"record"`
+}
@@ -9,6 +9,11 @@ type Controller interface {
 	Close()
 }
 
+// controller exempting some of its requests from the rate limit, e.g. polling of a running login
+type RateLimitExempter interface {
+	ExemptFromRateLimit(c *fiber.Ctx) bool
+}
+
 type Validatable interface {
 	Validate() error
 }
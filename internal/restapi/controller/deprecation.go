@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// legacy /ca routes are deprecated in favour of /v1 ones and removed after sunset
+var (
+	LegacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	LegacySunset       = time.Date(2027, time.October, 19, 0, 0, 0, 0, time.UTC)
+)
+
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+)
+
+// mark the route deprecated (RFC 9745) with its sunset (RFC 8594), successor is the
+// path of the route replacing it, :params are filled with the raw ones of request
+func Deprecated(successor string) fiber.Handler {
+	deprecation := fmt.Sprintf("@%d", LegacyDeprecatedAt.Unix())
+	sunset := LegacySunset.Format(http.TimeFormat)
+
+	return func(c *fiber.Ctx) error {
+		c.Set(HeaderDeprecation, deprecation)
+		c.Set(HeaderSunset, sunset)
+		c.Set(fiber.HeaderLink, fmt.Sprintf("<%s>; rel=\"successor-version\"", successorPath(c, successor)))
+
+		return c.Next()
+	}
+}
+
+func successorPath(c *fiber.Ctx, successor string) string {
+	segs := strings.Split(successor, "/")
+	for i, s := range segs {
+		if strings.HasPrefix(s, ":") {
+			segs[i] = c.Params(s[1:])
+		}
+	}
+
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,6 @@
+package controller
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+// request parameters or body can not be parsed
+var ErrBadRequest = errs.New(errs.CodeInvalidInput, "malformed request")
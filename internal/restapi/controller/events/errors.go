@@ -0,0 +1,6 @@
+package events
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errUnknownEventType = errs.New(errs.CodeInvalidInput, "unknown event type")
+var errInvalidLastEventId = errs.New(errs.CodeInvalidInput, "invalid last event id")
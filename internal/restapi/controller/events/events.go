@@ -0,0 +1,40 @@
+package events
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router pushes KRL and certificate lifecycle events to subscribers as server-sent events
+type Router struct {
+	app *app.App
+	// closed on shutdown to end open streams
+	done chan struct{}
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+	r.done = make(chan struct{})
+
+	grp := attchedTo.Group("/v1/events")
+
+	grp.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		grp.Get("/", auth.RequireScope(model.ScopeRead), r.Stream)
+	}
+}
+
+func (r *Router) Close() {
+	if r.done != nil {
+		close(r.done)
+	}
+}
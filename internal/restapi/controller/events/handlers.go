@@ -0,0 +1,206 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/event"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// comment line sent to keep proxies from closing idle streams
+	keepaliveInterval = 30 * time.Second
+	// reconnection delay suggested to clients, in milliseconds
+	retryDelay = 5000
+	// events buffered for a slow subscriber before dropping
+	streamBuffer = 64
+	// sent when events are lost, clients should refetch what they track
+	typeReset = "reset"
+)
+
+// stream events as text/event-stream, resuming after Last-Event-ID if it's given
+func (r *Router) Stream(c *fiber.Ctx) error {
+	var req StreamRequest
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return err
+	}
+
+	lastEventId := c.Get("Last-Event-ID", req.LastEventId)
+	resume := lastEventId != ""
+
+	var after uint64
+	if resume {
+		after, err = strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			return errInvalidLastEventId.Wrap(err)
+		}
+	}
+
+	f := newFilter(auth.TokenFromCtx(c), req)
+	baseURL := c.BaseURL()
+
+	missed, complete, events, cancel := r.app.Events.SubscribeSince(after, streamBuffer)
+	if !resume {
+		missed, complete = nil, true
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// stop nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	done := r.done
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		s := &stream{w: w, filter: f, baseURL: baseURL, last: after}
+
+		fmt.Fprintf(w, "retry: %d\n\n", retryDelay)
+		if !complete {
+			// the id may be from before a restart, start over from the next event
+			s.last = 0
+			s.reset()
+		}
+
+		for _, e := range missed {
+			s.send(e)
+		}
+
+		if s.flush() != nil {
+			return
+		}
+
+		ticker := time.NewTicker(keepaliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+
+				s.send(e)
+			case <-ticker.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			case <-done:
+				return
+			}
+
+			if s.flush() != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// event stream of a subscriber
+type stream struct {
+	w       *bufio.Writer
+	filter  *filter
+	baseURL string
+	// id of the latest event seen, sent or not
+	last uint64
+}
+
+func (s *stream) send(e *event.Event) {
+	if e.Id <= s.last {
+		return
+	}
+
+	// the subscriber was too slow and the bus dropped some events
+	if s.last != 0 && e.Id > s.last+1 {
+		s.reset()
+	}
+	s.last = e.Id
+
+	if !s.filter.match(e) {
+		return
+	}
+
+	data := e.Data
+	if krl, ok := data.(*service.KRLEvent); ok {
+		data = &KRLEventResp{KRLEvent: krl, URL: s.baseURL + "/v1/cas/" + krl.Role + "/krl"}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, b)
+}
+
+// without id, so that the client keeps resuming from where it has been
+func (s *stream) reset() {
+	fmt.Fprintf(s.w, "event: %s\ndata: {}\n\n", typeReset)
+}
+
+func (s *stream) flush() error {
+	return s.w.Flush()
+}
+
+// events a subscriber asked for and is allowed to receive
+type filter struct {
+	role  string
+	types map[string]bool
+}
+
+func newFilter(t *model.Token, req StreamRequest) *filter {
+	f := &filter{types: make(map[string]bool)}
+	if ct, err := model.ParseCertType(req.Role); req.Role != "" && err == nil {
+		f.role = model.FormatType(ct)
+	}
+
+	wanted := req.SplitedTypes()
+	if len(wanted) == 0 {
+		for typ := range typeScopes {
+			wanted = append(wanted, typ)
+		}
+	}
+
+	for _, typ := range wanted {
+		if t.HasScope(typeScopes[typ]) {
+			f.types[typ] = true
+		}
+	}
+
+	return f
+}
+
+func (f *filter) match(e *event.Event) bool {
+	if !f.types[e.Type] {
+		return false
+	}
+
+	return f.role == "" || f.role == roleOf(e)
+}
+
+func roleOf(e *event.Event) string {
+	switch d := e.Data.(type) {
+	case *service.KRLEvent:
+		return d.Role
+	case *service.CertEvent:
+		return d.Role
+	}
+
+	// break-glass certificates are user certificates
+	return model.FormatType(model.CerTypeUser)
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/event"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+)
+
+// scope required to receive each type of events
+var typeScopes = map[string]string{
+	event.TypeKRL:         model.ScopeRead,
+	event.TypeCertIssued:  model.ScopeAdmin,
+	event.TypeCertRevoked: model.ScopeAdmin,
+	event.TypeBreakGlass:  model.ScopeAdmin,
+}
+
+type StreamRequest struct {
+	// user or host, all roles if it's empty
+	Role string `query:"role"`
+	// comma separated event types, every type the token may receive if it's empty
+	Types string `query:"types"`
+	// for clients unable to set Last-Event-ID header
+	LastEventId string `query:"last_event_id"`
+}
+
+func (sr StreamRequest) SplitedTypes() []string {
+	if sr.Types == "" {
+		return nil
+	}
+
+	return strings.Split(sr.Types, ",")
+}
+
+func (sr StreamRequest) Validate() error {
+	if sr.Role != "" {
+		_, err := model.ParseCertType(sr.Role)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range sr.SplitedTypes() {
+		if _, ok := typeScopes[t]; !ok {
+			return errUnknownEventType
+		}
+	}
+
+	return nil
+}
+
+// KRL event along with where to download it
+type KRLEventResp struct {
+	*service.KRLEvent
+	URL string `json:"url"`
+}
@@ -0,0 +1,5 @@
+package inspect
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errMissingCert = errs.New(errs.CodeInvalidInput, "certificate is required")
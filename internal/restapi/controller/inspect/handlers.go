@@ -0,0 +1,139 @@
+package inspect
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// decode the certificate in body and check it against CA keys, KRL and certificate store
+func (r *Router) Verify(c *fiber.Ctx) error {
+	var req VerifyRequest
+	if c.Is("json") {
+		err := c.BodyParser(&req)
+		if err != nil {
+			return controller.ErrBadRequest.Wrap(err)
+		}
+	} else {
+		req.Cert = string(bytes.TrimSpace(c.Body()))
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	cert, err := utils.ParseSSHCert([]byte(req.Cert))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(r.report(cert)))
+}
+
+func (r *Router) report(cert *ssh.Certificate) *Report {
+	res := &Report{
+		Problems:        make([]string, 0),
+		KeyId:           cert.KeyId,
+		Serial:          cert.Serial,
+		Type:            "user",
+		PublicKey:       ssh.FingerprintSHA256(cert.Key),
+		SigningKey:      ssh.FingerprintSHA256(cert.SignatureKey),
+		ValidAfter:      time.Unix(int64(cert.ValidAfter), 0).UTC(),
+		Principals:      cert.ValidPrincipals,
+		CriticalOptions: cert.CriticalOptions,
+		Extensions:      cert.Extensions,
+	}
+	if cert.CertType == ssh.HostCert {
+		res.Type = "host"
+	}
+	if res.Principals == nil {
+		res.Principals = make([]string, 0)
+	}
+
+	problem := func(format string, args ...any) {
+		res.Problems = append(res.Problems, fmt.Sprintf(format, args...))
+	}
+
+	// signer
+	var signer *service.SSHCertCAService
+	for _, ca := range []*service.SSHCertCAService{r.app.UserCA, r.app.HostCA} {
+		known, current := ca.IsAuthority(cert.SignatureKey)
+		if known {
+			signer = ca
+			res.SignedBy = &Signer{Role: model.FormatType(ca.Role()), Current: current}
+			break
+		}
+	}
+
+	res.SignatureValid = utils.VerifySSHCertSignature(cert) == nil
+	if !res.SignatureValid {
+		problem("signature does not match the signing key")
+	}
+
+	switch {
+	case res.SignedBy == nil:
+		problem("not signed by any key of this CA, signing key is %s", res.SigningKey)
+	case !res.SignedBy.Current:
+		problem("signed by a previous key of the %s CA, hosts trusting only the current key refuse it", res.SignedBy.Role)
+	}
+
+	if res.SignedBy != nil && res.SignedBy.Role != res.Type {
+		problem("%s certificate signed by the %s CA", res.Type, res.SignedBy.Role)
+	}
+
+	// validity window
+	now := time.Now()
+	if now.Before(res.ValidAfter) {
+		problem("not valid before %s", res.ValidAfter.Format(time.RFC3339))
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		end := time.Unix(int64(cert.ValidBefore), 0).UTC()
+		res.ValidBefore = &end
+
+		remaining := int64(end.Sub(now) / time.Second)
+		if remaining <= 0 {
+			remaining = 0
+			problem("expired at %s", end.Format(time.RFC3339))
+		}
+		res.Remaining = &remaining
+	}
+
+	if len(cert.ValidPrincipals) == 0 {
+		problem("no principals")
+	}
+
+	if signer == nil {
+		return res
+	}
+
+	// revocation and store of the signing CA
+	res.Revoked = signer.IsRevoked(cert)
+	if res.Revoked {
+		problem("revoked by the present KRL of the %s CA", res.SignedBy.Role)
+
+		if rev, err := signer.GetRevocation(cert.KeyId); err == nil {
+			res.Revocation = rev
+		}
+	}
+
+	stored, err := signer.GetCert(cert.KeyId)
+	if err == nil {
+		if sc, err := utils.ParseSSHCert([]byte(stored.Content)); err == nil {
+			res.Stored = bytes.Equal(sc.Marshal(), cert.Marshal())
+		}
+	}
+	if !res.Stored {
+		problem("not found in the certificate store of the %s CA", res.SignedBy.Role)
+	}
+
+	res.Valid = len(res.Problems) == 0
+
+	return res
+}
@@ -0,0 +1,42 @@
+package inspect
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router tells what the CA knows about a certificate, to find out why sshd refuses it
+type Router struct {
+	app *app.App
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	v1 := attchedTo.Group("/v1/verify")
+
+	v1.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		v1.Post("/", auth.RequireScope(model.ScopeRead), r.Verify)
+	}
+
+	grp := attchedTo.Group("/ca/verify")
+
+	grp.Use(r.app.Auth.Middleware())
+
+	// legacy routes, kept until sunset
+	{
+		grp.Post("/", controller.Deprecated("/v1/verify"), auth.RequireScope(model.ScopeRead), r.Verify)
+	}
+}
+
+func (r *Router) Close() {}
@@ -0,0 +1,59 @@
+package inspect
+
+import (
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+// certificate in authorized_keys format, the content of a -cert.pub file
+type VerifyRequest struct {
+	Cert string `json:"cert"`
+}
+
+func (vr *VerifyRequest) Validate() error {
+	if vr.Cert == "" {
+		return errMissingCert
+	}
+
+	return nil
+}
+
+// CA key which signed the certificate
+type Signer struct {
+	Role string `json:"role"`
+	// false if it's a previous key of the CA
+	Current bool `json:"current"`
+}
+
+// what the CA knows about a certificate, valid if there is no problem
+type Report struct {
+	Valid    bool     `json:"valid"`
+	Problems []string `json:"problems"`
+
+	KeyId  string `json:"key_id"`
+	Serial uint64 `json:"serial,string"`
+	Type   string `json:"type"` // user or host
+	// SHA256 fingerprints
+	PublicKey  string `json:"public_key"`
+	SigningKey string `json:"signing_key"`
+	// null if none of CA keys signed it
+	SignedBy       *Signer `json:"signed_by"`
+	SignatureValid bool    `json:"signature_valid"`
+
+	ValidAfter time.Time `json:"valid_after"`
+	// null if it never expires
+	ValidBefore *time.Time `json:"valid_before"`
+	// seconds until it expires, null if it never expires
+	Remaining *int64 `json:"remaining"`
+
+	// revoked by the present KRL of the signing CA
+	Revoked    bool              `json:"revoked"`
+	Revocation *model.Revocation `json:"revocation,omitempty"`
+	// issued by the CA and kept in its store
+	Stored bool `json:"stored"`
+
+	Principals      []string          `json:"principals"`
+	CriticalOptions map[string]string `json:"critical_options"`
+	Extensions      map[string]string `json:"extensions"`
+}
@@ -0,0 +1,13 @@
+package login
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errSessionNotFound = errs.New(errs.CodeNotFound, "login session not found or expired")
+var errNoPrincipals = errs.New(errs.CodeNoPrincipals, "no principal derived from identity")
+var errInvalidInput = errs.New(errs.CodeInvalidInput, "invalid input")
+var errNotLoopback = errs.New(errs.CodeInvalidInput, "redirect_uri must be a loopback address, e.g. http://127.0.0.1:<port>/callback")
+var errStateMismatch = errs.New(errs.CodeInvalidInput, "state does not match the login session")
+var errProviderUnavailable = errs.New(errs.CodeUnavailable, "oidc provider unavailable")
+
+// held back requests are collected by the token that made them, which logins have none of
+var errApprovalRequired = errs.New(errs.CodePolicyDenied, "certificate requires approval, request it with a token instead of logging in")
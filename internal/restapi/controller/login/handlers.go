@@ -0,0 +1,263 @@
+package login
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/oidc"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// start authorization code flow for the public key in body. the provider redirects to the loopback address
+// of the client, so only the client started the login receives the code and nobody else can collect the certificate
+func (r *Router) Login(c *fiber.Ctx) error {
+	var req LoginRequest
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey(c.Body())
+	if err != nil {
+		return err
+	}
+
+	p, err := r.provider(c.Context())
+	if err != nil {
+		return err
+	}
+
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return err
+	}
+
+	// the session id is kept by the client, state goes through browser and provider
+	s := r.sessions.create(pubkey, c.IP(), sessionTTL)
+	s.state = utils.RandomSha1Hex()
+	s.redirectURI = req.RedirectURI
+	s.codeVerifier = verifier
+
+	return c.JSON(controller.NewCommonRespWithData(LoginResp{
+		Id:        s.id,
+		State:     s.state,
+		AuthURL:   p.AuthCodeURL(s.state, verifier, s.redirectURI),
+		ExpiresAt: s.expiresAt,
+	}))
+}
+
+// exchange the code the client received on its loopback redirect, the certificate is returned at once.
+// a session is exchanged only once whether it succeeds or not
+func (r *Router) Exchange(c *fiber.Ctx) error {
+	var req ExchangeRequest
+	err := c.BodyParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	p, err := r.provider(c.Context())
+	if err != nil {
+		return err
+	}
+
+	var resp ResultResp
+	err = r.sessions.with(req.Id, func(s *session) error {
+		if s.codeVerifier == "" || s.cert != nil {
+			return errSessionNotFound
+		}
+
+		if subtle.ConstantTimeCompare([]byte(req.State), []byte(s.state)) != 1 {
+			return errStateMismatch
+		}
+
+		id, err := p.Exchange(c.Context(), req.Code, s.codeVerifier, s.redirectURI)
+		if err != nil {
+			return err
+		}
+
+		err = r.sign(s, id)
+		if err != nil {
+			return err
+		}
+
+		resp.Status, resp.Cert = StatusDone, s.cert
+
+		return nil
+	})
+	r.sessions.remove(req.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(resp))
+}
+
+// start device code flow for the public key in body
+func (r *Router) Device(c *fiber.Ctx) error {
+	pubkey, err := utils.ParseSSHPublicKey(c.Body())
+	if err != nil {
+		return err
+	}
+
+	p, err := r.provider(c.Context())
+	if err != nil {
+		return err
+	}
+
+	da, err := p.StartDeviceAuth(c.Context())
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(da.ExpiresIn) * time.Second
+	if ttl <= 0 || ttl > sessionTTL {
+		ttl = sessionTTL
+	}
+
+	s := r.sessions.create(pubkey, c.IP(), ttl)
+	s.device = da
+
+	return c.JSON(controller.NewCommonRespWithData(DeviceResp{
+		Id:                      s.id,
+		UserCode:                da.UserCode,
+		VerificationURI:         da.VerificationURI,
+		VerificationURIComplete: da.VerificationURIComplete,
+		Interval:                da.Interval,
+		ExpiresAt:               s.expiresAt,
+	}))
+}
+
+// poll result of device code flow, the request is held until the login finishes or resultWait passes,
+// so clients polling it stay within the rate limit. the certificate can be collected only once
+func (r *Router) Result(c *fiber.Ctx) error {
+	var req ResultRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	deadline := time.Now().Add(resultWait)
+	for {
+		resp, err := r.result(c.Context(), req.Id)
+		if err != nil || resp.Status == StatusDone {
+			r.sessions.remove(req.Id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if resp.Status == StatusDone || time.Now().After(deadline) {
+			return c.JSON(controller.NewCommonRespWithData(resp))
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+func (r *Router) result(ctx context.Context, id string) (resp ResultResp, err error) {
+	err = r.sessions.with(id, func(s *session) error {
+		if s.device == nil {
+			return errSessionNotFound
+		}
+
+		if s.cert == nil {
+			err := r.pollDevice(ctx, s)
+			if err != nil {
+				return err
+			}
+		}
+
+		if s.cert == nil {
+			resp.Status = StatusPending
+			return nil
+		}
+
+		resp.Status = StatusDone
+		resp.Cert = s.cert
+
+		return nil
+	})
+
+	return
+}
+
+// poll the provider token endpoint no more often than the interval it asked for
+func (r *Router) pollDevice(ctx context.Context, s *session) error {
+	if time.Now().Before(s.nextPoll) {
+		return nil
+	}
+
+	p, err := r.provider(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := p.PollDeviceToken(ctx, s.device.DeviceCode)
+	if errors.Is(err, oidc.ErrSlowDown) {
+		s.device.Interval += 5
+	}
+	if errors.Is(err, oidc.ErrAuthorizationPending) || errors.Is(err, oidc.ErrSlowDown) {
+		s.nextPoll = time.Now().Add(time.Duration(s.device.Interval) * time.Second)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.sign(s, id)
+}
+
+// issue short-lived user certificate with principals derived from identity
+func (r *Router) sign(s *session, id *oidc.Identity) error {
+	// with no principal claims, principals come from the policy resolving the identity
+	principals := oidc.PrincipalsFromClaims(id.Claims, r.cfg.PrincipalClaims, r.cfg.EmailDomains)
+	if len(principals) == 0 && config.Cfg.LDAP == nil {
+		return errNoPrincipals
+	}
+
+	ttl := time.Duration(r.cfg.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = 8 * time.Hour
+	}
+
+	name := id.Name(r.cfg.EmailDomains)
+
+	cert, err := r.app.UserCA.Issue(&service.SignRequest{
+		PublicKey:  s.pubkey,
+		Requester:  name,
+		ClientIP:   s.clientIP,
+		Identity:   name,
+		Principals: principals,
+		TTL:        ttl,
+		// claims may map to several principals
+		AllPrincipals: true,
+	})
+	if errors.Is(err, policy.ErrApprovalRequired) {
+		return errApprovalRequired
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("oidc login of %s, issued %s", name, cert.KeyId)
+
+	s.cert = &cert
+
+	return nil
+}
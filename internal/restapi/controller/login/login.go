@@ -0,0 +1,88 @@
+package login
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/oidc"
+	"github.com/gofiber/fiber/v2"
+)
+
+const resultPathPrefix = "/oidc/result/"
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router issues short-lived user certificates to users logged in with OIDC,
+// by authorization code flow with PKCE redirecting to a loopback address of the client, or device code flow
+type Router struct {
+	app      *app.App
+	cfg      *config.OIDCConfig
+	sessions *sessionStore
+
+	providerLock *sync.Mutex
+	oidcProvider *oidc.Provider
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.cfg = config.Cfg.OIDC
+	if r.cfg == nil {
+		return
+	}
+
+	r.app = app.Get()
+	r.sessions = newSessionStore()
+	r.providerLock = &sync.Mutex{}
+
+	grp := attchedTo.Group("/oidc")
+
+	// routes
+	{
+		grp.Post("/login", r.Login)
+		grp.Post("/exchange", r.Exchange)
+		grp.Post("/device", r.Device)
+		grp.Get("/result/:id", r.Result)
+	}
+}
+
+// discover provider on first use, so the server starts even if the provider is down
+func (r *Router) provider(ctx context.Context) (*oidc.Provider, error) {
+	r.providerLock.Lock()
+	defer r.providerLock.Unlock()
+
+	if r.oidcProvider != nil {
+		return r.oidcProvider, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	p, err := oidc.NewProvider(ctx, r.cfg.Issuer, r.cfg.ClientID, r.cfg.ClientSecret, r.cfg.Scopes)
+	if err != nil {
+		log.Printf("oidc discovery of %s failed: %v", r.cfg.Issuer, err)
+		return nil, errProviderUnavailable
+	}
+
+	r.oidcProvider = p
+
+	return p, nil
+}
+
+// device logins poll their result until the user finishes at the provider, which may take minutes.
+// only results of live sessions polled from the address that started them are exempt
+func (r *Router) ExemptFromRateLimit(c *fiber.Ctx) bool {
+	if r.sessions == nil || c.Method() != fiber.MethodGet || !strings.HasPrefix(c.Path(), resultPathPrefix) {
+		return false
+	}
+
+	return r.sessions.live(strings.TrimPrefix(c.Path(), resultPathPrefix), c.IP())
+}
+
+func (r *Router) Close() {}
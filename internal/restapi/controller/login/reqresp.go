@@ -0,0 +1,66 @@
+package login
+
+import (
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/oidc"
+)
+
+type LoginRequest struct {
+	RedirectURI string `query:"redirect_uri"`
+}
+
+func (lr LoginRequest) Validate() error {
+	if !oidc.IsLoopbackRedirect(lr.RedirectURI) {
+		return errNotLoopback
+	}
+
+	return nil
+}
+
+// authorization code received by the client on its loopback redirect
+type ExchangeRequest struct {
+	Id    string `json:"id"`
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+func (er ExchangeRequest) Validate() error {
+	if er.Id == "" || er.State == "" || er.Code == "" {
+		return errInvalidInput
+	}
+
+	return nil
+}
+
+type ResultRequest struct {
+	Id string `params:"id"`
+}
+
+type LoginResp struct {
+	Id string `json:"id"`
+	// the client checks the state of redirect against it
+	State     string    `json:"state"`
+	AuthURL   string    `json:"auth_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type DeviceResp struct {
+	Id                      string    `json:"id"`
+	UserCode                string    `json:"user_code"`
+	VerificationURI         string    `json:"verification_uri"`
+	VerificationURIComplete string    `json:"verification_uri_complete,omitempty"`
+	Interval                int       `json:"interval"`
+	ExpiresAt               time.Time `json:"expires_at"`
+}
+
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+)
+
+type ResultResp struct {
+	Status string      `json:"status"`
+	Cert   *model.Cert `json:"cert,omitempty"`
+}
@@ -0,0 +1,104 @@
+package login
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/oidc"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+const sessionTTL = 10 * time.Minute
+
+// longest time a result request is held, below the timeout of clients
+const resultWait = 20 * time.Second
+
+// pending login of a public key, the certificate is kept until it's collected
+type session struct {
+	id           string
+	pubkey       ssh.PublicKey
+	clientIP     string
+	state        string
+	redirectURI  string
+	codeVerifier string
+	device       *oidc.DeviceAuth
+	nextPoll     time.Time
+	expiresAt    time.Time
+	cert         *model.Cert
+	lock         *sync.Mutex
+}
+
+type sessionStore struct {
+	store map[string]*session
+	lock  *sync.Mutex
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		store: make(map[string]*session),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (ss *sessionStore) create(pubkey ssh.PublicKey, clientIP string, ttl time.Duration) *session {
+	s := &session{
+		id:        uuid.NewString(),
+		pubkey:    pubkey,
+		clientIP:  clientIP,
+		expiresAt: time.Now().Add(ttl),
+		lock:      &sync.Mutex{},
+	}
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	now := time.Now()
+	for id, s := range ss.store {
+		if now.After(s.expiresAt) {
+			delete(ss.store, id)
+		}
+	}
+
+	ss.store[s.id] = s
+
+	return s
+}
+
+// run fn with the session locked, so a session is never polled or signed twice at once
+func (ss *sessionStore) with(id string, fn func(s *session) error) error {
+	ss.lock.Lock()
+	s, exist := ss.store[id]
+	if exist && time.Now().After(s.expiresAt) {
+		delete(ss.store, id)
+		exist = false
+	}
+	ss.lock.Unlock()
+
+	if !exist {
+		return errSessionNotFound
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return fn(s)
+}
+
+// whether the session exists, has not expired and is started from the address
+func (ss *sessionStore) live(id, clientIP string) bool {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	s, exist := ss.store[id]
+
+	return exist && time.Now().Before(s.expiresAt) && s.clientIP == clientIP
+}
+
+func (ss *sessionStore) remove(id string) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	delete(ss.store, id)
+}
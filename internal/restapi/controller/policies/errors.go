@@ -0,0 +1,5 @@
+package policies
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errInvalidRequired = errs.New(errs.CodeInvalidInput, "at least one approval is required")
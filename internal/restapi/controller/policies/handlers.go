@@ -0,0 +1,82 @@
+package policies
+
+import (
+	"log"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (r *Router) Get(c *fiber.Ctx) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	res := &PoliciesResp{
+		User: &PolicyResp{Approval: config.Cfg.UserCA.Approval},
+		Host: &PolicyResp{Approval: config.Cfg.HostCA.Approval},
+	}
+	if config.Cfg.LDAP != nil {
+		res.User.Groups = config.Cfg.LDAP.Groups
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(res))
+}
+
+// replace approval rule of the CA
+func (r *Router) SetApproval(c *fiber.Ctx) error {
+	var approval config.ApprovalConfig
+	err := c.BodyParser(&approval)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	if err := validateApproval(&approval); err != nil {
+		return err
+	}
+
+	return r.updateApproval(c, &approval)
+}
+
+// requests of the CA no longer need approval
+func (r *Router) DeleteApproval(c *fiber.Ctx) error {
+	return r.updateApproval(c, nil)
+}
+
+func (r *Router) updateApproval(c *fiber.Ctx, approval *config.ApprovalConfig) error {
+	var req RoleRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err = r.app.SetApproval(ct, approval)
+	if err != nil {
+		return err
+	}
+
+	cacfg := config.Cfg.UserCA
+	if ct == model.CertTypeHost {
+		cacfg = config.Cfg.HostCA
+	}
+	cacfg.Approval = approval
+
+	err = config.Save(config.Cfg)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("approval rule of %s CA updated by %s", model.FormatType(ct), auth.TokenFromCtx(c).Name)
+
+	return c.JSON(controller.NewCommonRespWithData(approval))
+}
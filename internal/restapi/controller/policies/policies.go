@@ -0,0 +1,40 @@
+package policies
+
+import (
+	"sync"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router shows the policies of both CAs and replaces approval rules at runtime, saving them to config file
+type Router struct {
+	app *app.App
+	// serializes updates of config file
+	lock *sync.Mutex
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+	r.lock = &sync.Mutex{}
+
+	v1 := attchedTo.Group("/v1/policies")
+
+	v1.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		v1.Get("/", auth.RequireScope(model.ScopeAdmin), r.Get)
+		v1.Put("/:role/approval", auth.RequireScope(model.ScopeAdmin), r.SetApproval)
+		v1.Delete("/:role/approval", auth.RequireScope(model.ScopeAdmin), r.DeleteApproval)
+	}
+}
+
+func (r *Router) Close() {}
@@ -0,0 +1,30 @@
+package policies
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/identity"
+)
+
+type RoleRequest struct {
+	Role string `params:"role"`
+}
+
+func validateApproval(approval *config.ApprovalConfig) error {
+	if approval.Required < 1 {
+		return errInvalidRequired
+	}
+
+	return nil
+}
+
+// policy of a CA, approval is null if no request requires approval
+type PolicyResp struct {
+	Approval *config.ApprovalConfig `json:"approval"`
+	// LDAP group mappings, user CA only
+	Groups map[string]*identity.GroupMapping `json:"groups,omitempty"`
+}
+
+type PoliciesResp struct {
+	User *PolicyResp `json:"user"`
+	Host *PolicyResp `json:"host"`
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/gofiber/fiber/v2"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+// path of the error code catalogue, problem types point into it
+const ErrorCataloguePath = "/errors"
+
+// RFC 7807 problem details, code is the stable error code from the catalogue
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+func NewProblem(err error, instance string) *Problem {
+	kind := errs.KindOf(err)
+	code := errs.CodeOf(err)
+
+	// errors of the web framework carry HTTP status only
+	var fe *fiber.Error
+	if errors.As(err, &fe) && kind == errs.Internal {
+		kind = errs.KindOfHTTPStatus(fe.Code)
+		code = errs.GenericCode(kind)
+	}
+
+	// causes of internal errors, e.g. of the database, are for the server log only
+	detail := err.Error()
+	if kind == errs.Internal {
+		detail = errs.Title(code)
+	}
+
+	return &Problem{
+		Type:     ErrorCataloguePath + "#" + code,
+		Title:    errs.Title(code),
+		Status:   kind.HTTPStatus(),
+		Detail:   detail,
+		Instance: instance,
+		Code:     code,
+	}
+}
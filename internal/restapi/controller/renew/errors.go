@@ -0,0 +1,5 @@
+package renew
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errInvalidInput = errs.New(errs.CodeInvalidInput, "invalid input")
@@ -0,0 +1,95 @@
+package renew
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
+	"github.com/gofiber/fiber/v2"
+)
+
+// issue a nonce for the certificate in body, it's checked before issuing so
+// strangers can't fill up the challenge store
+func (r *Router) Challenge(c *fiber.Ctx) error {
+	var req ChallengeRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.app.CAByRole(ct)
+	if err != nil {
+		return err
+	}
+
+	cert, err := utils.ParseSSHCert(c.Body())
+	if err != nil {
+		return err
+	}
+
+	err = signer.CheckRenewable(cert)
+	if err != nil {
+		return err
+	}
+
+	ch, err := r.app.Challenges.Issue(cert)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(ch))
+}
+
+// renew the certificate in body once the challenge signed by its key is verified
+func (r *Router) Renew(c *fiber.Ctx) error {
+	var req RenewRequest
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+	err = c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.app.CAByRole(ct)
+	if err != nil {
+		return err
+	}
+
+	cert, err := utils.ParseSSHCert(c.Body())
+	if err != nil {
+		return err
+	}
+
+	sig, err := verify.ParseSignature(req.Signature)
+	if err != nil {
+		return verify.ErrBadSignature
+	}
+
+	err = r.app.Challenges.Verify(req.Challenge, cert, sig)
+	if err != nil {
+		return err
+	}
+
+	renewed, err := signer.Renew(cert)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(renewed))
+}
@@ -0,0 +1,31 @@
+package renew
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router renews certificates for holders of a still valid certificate, authenticated by
+// signing a challenge with the certificate's key instead of bearer token
+type Router struct {
+	app *app.App
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	grp := attchedTo.Group("/renew")
+
+	// routes
+	{
+		grp.Post("/challenge/:role", r.Challenge)
+		grp.Post("/:role", r.Renew)
+	}
+}
+
+func (r *Router) Close() {}
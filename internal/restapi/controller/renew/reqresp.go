@@ -0,0 +1,19 @@
+package renew
+
+type ChallengeRequest struct {
+	Role string `params:"role"`
+}
+
+type RenewRequest struct {
+	Role      string `query:"-" params:"role"`
+	Challenge string `query:"challenge"`
+	Signature string `query:"signature"`
+}
+
+func (rr *RenewRequest) Validate() error {
+	if rr.Role == "" || rr.Challenge == "" || rr.Signature == "" {
+		return errInvalidInput
+	}
+
+	return nil
+}
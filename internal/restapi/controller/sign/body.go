@@ -0,0 +1,200 @@
+package sign
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+	"github.com/gofiber/fiber/v2"
+)
+
+// media types the sign endpoint accepts
+const (
+	mimeJSON      = "application/json"
+	mimeMultipart = "multipart/form-data"
+	mimeText      = "text/plain"
+	mimeOctet     = "application/octet-stream"
+	// curl --data-binary without content type
+	mimeForm = "application/x-www-form-urlencoded"
+)
+
+var supportedSignMedia = []string{mimeJSON, mimeMultipart, mimeText, mimeOctet, mimeForm}
+
+// lifetime given as duration string, e.g. "8h", or number of seconds, at least a second
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var secs uint64
+	if err := json.Unmarshal(b, &secs); err == nil {
+		if secs == 0 {
+			return errInvalidTTL
+		}
+
+		*d = Duration(time.Duration(secs) * time.Second)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errInvalidTTL
+	}
+
+	v, err := parseTTL(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// empty means default, anything shorter than a second is refused rather than taken as default
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if secs, perr := strconv.ParseUint(s, 10, 64); perr == nil {
+		d, err = time.Duration(secs)*time.Second, nil
+	}
+	if err != nil || d < time.Second {
+		return 0, errInvalidTTL
+	}
+
+	return d, nil
+}
+
+// JSON sign request body
+type SignBody struct {
+	PublicKey       string            `json:"pubkey"` // authorized_keys format
+	Principals      []string          `json:"principals"`
+	TTL             Duration          `json:"ttl"`
+	Extensions      map[string]string `json:"extensions"`
+	CriticalOptions map[string]string `json:"critical_options"`
+	Challenge       string            `json:"challenge"`
+	Signature       string            `json:"signature"`
+}
+
+// parse the public key and parameters from body by content type, parameters in body override query ones.
+// raw body is the authorized_keys formatted public key
+func parseSignBody(c *fiber.Ctx, req *SignRequest) (pubkey []byte, err error) {
+	ct := string(c.Request().Header.ContentType())
+	mediaType := ""
+	if ct != "" {
+		mediaType, _, err = mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, unsupportedMedia(ct)
+		}
+	}
+
+	switch mediaType {
+	case mimeJSON:
+		return parseSignJSON(c, req)
+	case mimeMultipart:
+		return parseSignMultipart(c, req)
+	case "", mimeText, mimeOctet, mimeForm:
+		return c.Body(), nil
+	}
+
+	return nil, unsupportedMedia(mediaType)
+}
+
+func parseSignJSON(c *fiber.Ctx, req *SignRequest) ([]byte, error) {
+	var body SignBody
+
+	dec := json.NewDecoder(bytes.NewReader(c.Body()))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&body)
+	if err != nil {
+		return nil, controller.ErrBadRequest.Wrap(err)
+	}
+
+	if len(body.Principals) > 0 {
+		req.SignTo = strings.Join(body.Principals, ",")
+	}
+	if body.TTL > 0 {
+		req.TTL = uint64(time.Duration(body.TTL) / time.Second)
+	}
+	if body.Challenge != "" {
+		req.Challenge = body.Challenge
+	}
+	if body.Signature != "" {
+		req.Signature = body.Signature
+	}
+
+	if body.Extensions != nil || body.CriticalOptions != nil {
+		req.Options = &ca.CertOptions{
+			Extensions:      body.Extensions,
+			CriticalOptions: body.CriticalOptions,
+		}
+	}
+
+	return []byte(body.PublicKey), nil
+}
+
+// public key as file or value of pubkey field, other fields as query parameters
+func parseSignMultipart(c *fiber.Ctx, req *SignRequest) ([]byte, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, controller.ErrBadRequest.Wrap(err)
+	}
+
+	value := func(name string) string {
+		if v := form.Value[name]; len(v) > 0 {
+			return v[0]
+		}
+
+		return ""
+	}
+
+	if v := value("signto"); v != "" {
+		req.SignTo = v
+	}
+	if v := form.Value["principals"]; len(v) > 0 {
+		req.SignTo = strings.Join(v, ",")
+	}
+	if v := value("ttl"); v != "" {
+		ttl, err := parseTTL(v)
+		if err != nil {
+			return nil, err
+		}
+		req.TTL = uint64(ttl / time.Second)
+	}
+	if v := value("challenge"); v != "" {
+		req.Challenge = v
+	}
+	if v := value("signature"); v != "" {
+		req.Signature = v
+	}
+
+	if files := form.File["pubkey"]; len(files) > 0 {
+		f, err := files[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		// public keys are small, anything bigger is not one
+		return io.ReadAll(io.LimitReader(f, 64*1024))
+	}
+
+	if v := value("pubkey"); v != "" {
+		return []byte(v), nil
+	}
+
+	return nil, errMissingPubkey
+}
+
+func unsupportedMedia(mediaType string) error {
+	return errUnsupportedMedia.Wrap(fmt.Errorf("%q, expect one of %s", mediaType, strings.Join(supportedSignMedia, ", ")))
+}
@@ -1,7 +1,10 @@
 package sign
 
-import "errors"
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
 
-var errUnknownRole = errors.New("unknown role")
-var errInvalidAuthKey = errors.New("invalid auth key")
-var errInvalidInput = errors.New("invalid input")
+var errUnknownRole = errs.New(errs.CodeUnsupportedRole, "unknown role")
+var errInvalidInput = errs.New(errs.CodeInvalidInput, "invalid input")
+var errInvalidTTL = errs.New(errs.CodeInvalidInput, "invalid ttl, expect duration like 8h or number of seconds, at least a second")
+var errMissingPubkey = errs.New(errs.CodeInvalidKey, "missing pubkey")
+var errUnsupportedMedia = errs.New(errs.CodeUnsupportedMedia, "unsupported media type")
+var errNoCertToImport = errs.New(errs.CodeInvalidInput, "no certificate to import")
@@ -1,21 +1,32 @@
 package sign
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
 	"github.com/0w0mewo/ssh_cert_ca/internal/model"
 	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
+	"github.com/stripe/krl"
 )
 
 func (r *Router) GetCAPublickey(c *fiber.Ctx) error {
 	var req SignRequest
 	err := c.ParamsParser(&req)
 	if err != nil {
-		return err
+		return controller.ErrBadRequest.Wrap(err)
 	}
 
 	ct, err := model.ParseCertType(req.Role)
@@ -32,14 +43,14 @@ func (r *Router) GetCAPublickey(c *fiber.Ctx) error {
 
 }
 
-// TODO: get a list of reovked certs key id and base64 encoded ssh key revoke list(KRL) file
+// get base64 encoded ssh key revoke list(KRL) file, KRL version is used as ETag for conditional requests
 func (r *Router) GetRevoked(c *fiber.Ctx) error {
 	var req RevokeRequest
 
 	// parse request
 	err := c.ParamsParser(&req)
 	if err != nil {
-		return err
+		return controller.ErrBadRequest.Wrap(err)
 	}
 
 	if err := req.Validate(); err != nil {
@@ -56,16 +67,30 @@ func (r *Router) GetRevoked(c *fiber.Ctx) error {
 		return err
 	}
 
-	return c.JSON(controller.NewCommonRespWithData(signer.GetPresentRevokedListBase64()))
+	krl, version := signer.GetPresentRevokedListWithVersion()
+
+	etag := fmt.Sprintf("\"%d\"", version)
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(HeaderKRLVersion, strconv.FormatUint(version, 10))
+
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(base64.StdEncoding.EncodeToString(krl)))
 }
 
 // TODO: mark cert revoked on DB
 func (r *Router) Revoke(c *fiber.Ctx) error {
 	var req RevokeRequest
 
-	err := c.ParamsParser(&req)
+	err := c.QueryParser(&req)
 	if err != nil {
-		return err
+		return controller.ErrBadRequest.Wrap(err)
+	}
+	err = c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
 	}
 
 	if err := req.Validate(); err != nil {
@@ -82,7 +107,7 @@ func (r *Router) Revoke(c *fiber.Ctx) error {
 		return err
 	}
 
-	err = signer.Revoke(req.KeyId)
+	err = signer.RevokeWithReason(req.KeyId, req.Reason, auth.TokenFromCtx(c).Name)
 	if err != nil {
 		return err
 	}
@@ -90,6 +115,145 @@ func (r *Router) Revoke(c *fiber.Ctx) error {
 	return c.JSON(controller.NewCommonRespWithData(nil))
 }
 
+// list certificates of the CA by status, searching key ids and principals, soonest expiring first
+func (r *Router) ListCerts(c *fiber.Ctx) error {
+	var req ListRequest
+	err := c.QueryParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+	err = c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.getCAServiceByCertType(ct)
+	if err != nil {
+		return err
+	}
+
+	certs, err := signer.ListAllCerts()
+	if err != nil {
+		return err
+	}
+
+	revs, err := signer.ListRevocations()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	res := make([]*CertInfo, 0, len(certs))
+	for _, cert := range certs {
+		info := &CertInfo{Cert: cert, Status: StatusValid, Revocation: revs[cert.KeyId]}
+		if cert.Revoked {
+			info.Status = StatusRevoked
+		} else if cert.ValidEnd.Before(now) {
+			info.Status = StatusExpired
+		}
+
+		if req.Status != StatusAll && req.Status != info.Status {
+			continue
+		}
+
+		if parsed, err := utils.ParseSSHCert([]byte(cert.Content)); err == nil {
+			info.Serial = parsed.Serial
+			info.Principals = parsed.ValidPrincipals
+		}
+
+		if req.Query != "" && !matchCert(info, req.Query) {
+			continue
+		}
+
+		res = append(res, info)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ValidEnd.Before(res[j].ValidEnd)
+	})
+
+	return c.JSON(controller.NewCommonRespWithData(res))
+}
+
+func matchCert(info *CertInfo, query string) bool {
+	query = strings.ToLower(query)
+	if strings.Contains(strings.ToLower(info.KeyId), query) {
+		return true
+	}
+
+	for _, p := range info.Principals {
+		if strings.Contains(strings.ToLower(p), query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// key ids and serials revoked by the present KRL
+func (r *Router) GetRevokedContents(c *fiber.Ctx) error {
+	var req RevokeRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.getCAServiceByCertType(ct)
+	if err != nil {
+		return err
+	}
+
+	raw, version := signer.GetPresentRevokedListWithVersion()
+	k, err := krl.ParseKRL(raw)
+	if err != nil {
+		return err
+	}
+
+	res := &KRLContents{
+		Version:     version,
+		GeneratedAt: time.Unix(int64(k.GeneratedDate), 0),
+		Size:        len(raw),
+		KeyIds:      make([]string, 0),
+		Serials:     make([]string, 0),
+	}
+
+	for _, sec := range k.Sections {
+		cs, ok := sec.(*krl.KRLCertificateSection)
+		if !ok {
+			continue
+		}
+
+		for _, sub := range cs.Sections {
+			switch v := sub.(type) {
+			case *krl.KRLCertificateKeyID:
+				res.KeyIds = append(res.KeyIds, *v...)
+			case *krl.KRLCertificateSerialList:
+				for _, serial := range *v {
+					res.Serials = append(res.Serials, strconv.FormatUint(serial, 10))
+				}
+			case *krl.KRLCertificateSerialRange:
+				res.Serials = append(res.Serials, fmt.Sprintf("%d-%d", v.Min, v.Max))
+			}
+		}
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(res))
+}
+
 // TODO: save signed certs info to DB
 func (r *Router) Sign(c *fiber.Ctx) error {
 	var req SignRequest
@@ -97,9 +261,15 @@ func (r *Router) Sign(c *fiber.Ctx) error {
 	// parse request
 	err := c.QueryParser(&req)
 	if err != nil {
-		return err
+		return controller.ErrBadRequest.Wrap(err)
 	}
 	err = c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	// get public key and parameters from body
+	body, err := parseSignBody(c, &req)
 	if err != nil {
 		return err
 	}
@@ -109,8 +279,7 @@ func (r *Router) Sign(c *fiber.Ctx) error {
 		return err
 	}
 
-	// get public key from body
-	pubkey, err := utils.ParseSSHPublicKey(c.Body())
+	pubkey, err := utils.ParseSSHPublicKey(body)
 	if err != nil {
 		return err
 	}
@@ -126,15 +295,92 @@ func (r *Router) Sign(c *fiber.Ctx) error {
 		return err
 	}
 
+	// proof of possession
+	if r.requireSignedNonce(ct) {
+		sig, err := verify.ParseSignature(req.Signature)
+		if err != nil {
+			return verify.ErrBadSignature
+		}
+
+		err = r.challenges.Verify(req.Challenge, pubkey, sig)
+		if err != nil {
+			return err
+		}
+	}
+
 	// sign
-	cert, err := signer.Sign(pubkey, uuid.NewString(), req.SplitedSignTo(), time.Duration(req.TTL)*time.Second)
+	sreq := &service.SignRequest{
+		PublicKey:   pubkey,
+		Requester:   auth.TokenFromCtx(c).Name,
+		RequesterId: auth.TokenFromCtx(c).Id,
+		ClientIP:    c.IP(),
+		Principals:  req.SplitedSignTo(),
+		TTL:         time.Duration(req.TTL) * time.Second,
+		Options:     req.Options,
+	}
+
+	cert, err := signer.Issue(sreq)
+	if errors.Is(err, policy.ErrApprovalRequired) {
+		return r.submitForApproval(c, ct, sreq)
+	}
 	if err != nil {
 		return err
 	}
 
+	// bare certificate line, ready to be saved as -cert.pub
+	if c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextPlain) == fiber.MIMETextPlain {
+		return c.SendString(cert.Content + "\n")
+	}
+
 	return c.JSON(NewCertAsCommonResp(cert))
 }
 
+// hold the request back until approved, requester collects the certificate from /v1/requests/:id
+func (r *Router) submitForApproval(c *fiber.Ctx, role model.RoleType, sreq *service.SignRequest) error {
+	cr, err := r.approvals.Submit(role, sreq)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("request %s for %s by %s is pending approval", cr.Id, cr.Principals, cr.Requester)
+
+	return c.Status(fiber.StatusAccepted).JSON(controller.NewCommonRespWithData(cr))
+}
+
+// issue a nonce to be signed by the private key of the public key in body
+func (r *Router) Challenge(c *fiber.Ctx) error {
+	var req ChallengeRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	_, err = model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey(c.Body())
+	if err != nil {
+		return err
+	}
+
+	ch, err := r.challenges.Issue(pubkey)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(ch))
+}
+
+func (r *Router) requireSignedNonce(role model.RoleType) bool {
+	if role == model.CertTypeHost {
+		return config.Cfg.HostCA.RequireSignedNonce
+	}
+
+	return config.Cfg.UserCA.RequireSignedNonce
+}
+
 func (r *Router) getCAServiceByCertType(role model.RoleType) (*service.SSHCertCAService, error) {
 	var signer *service.SSHCertCAService
 
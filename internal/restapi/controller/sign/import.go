@@ -0,0 +1,91 @@
+package sign
+
+import (
+	"log"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// import certificates signed by the CA key elsewhere, one per line of body.
+// each certificate is imported on its own, failures are reported along with the others
+func (r *Router) ImportCerts(c *fiber.Ctx) error {
+	var req SignRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.getCAServiceByCertType(ct)
+	if err != nil {
+		return err
+	}
+
+	res := make([]*ImportResult, 0)
+	for _, line := range strings.Split(string(c.Body()), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cert, err := utils.ParseSSHCert([]byte(line))
+		if err == nil {
+			_, err = signer.ImportCert(cert)
+		}
+		if err != nil {
+			res = append(res, &ImportResult{Code: errs.CodeOf(err), Error: err.Error()})
+			continue
+		}
+
+		res = append(res, &ImportResult{KeyId: cert.KeyId, Revoked: signer.IsRevoked(cert)})
+	}
+
+	if len(res) == 0 {
+		return errNoCertToImport
+	}
+
+	log.Printf("%d certificates imported to %s CA by %s", len(res), model.FormatType(ct), auth.TokenFromCtx(c).Name)
+
+	return c.JSON(controller.NewCommonRespWithData(res))
+}
+
+// merge a KRL signed by the CA key into KRLs of the CA, revoking stored certificates it revokes
+func (r *Router) ImportKRL(c *fiber.Ctx) error {
+	var req SignRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	ct, err := model.ParseCertType(req.Role)
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.getCAServiceByCertType(ct)
+	if err != nil {
+		return err
+	}
+
+	// body is reused by fasthttp once the handler returns
+	raw := append([]byte(nil), c.Body()...)
+
+	rec, revoked, err := signer.ImportKRL(raw)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("KRL %s imported to %s CA by %s", rec.Id, model.FormatType(ct), auth.TokenFromCtx(c).Name)
+
+	return c.JSON(controller.NewCommonRespWithData(&KRLImportResp{ImportedKRL: rec, Revoked: revoked}))
+}
@@ -2,15 +2,24 @@ package sign
 
 import (
 	"strings"
+	"time"
 
 	"github.com/0w0mewo/ssh_cert_ca/internal/model"
 	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
 )
 
+const HeaderKRLVersion = "X-KRL-Version"
+
 type SignRequest struct {
 	Role   string `query:"-" params:"role"`
 	SignTo string `query:"signto"`
 	TTL    uint64 `query:"ttl"`
+	// proof of possession, id of the challenge and base64 encoded signature of its nonce
+	Challenge string `query:"challenge"`
+	Signature string `query:"signature"`
+	// extensions and critical options, JSON body only
+	Options *ca.CertOptions `query:"-"`
 }
 
 func (srq SignRequest) SplitedSignTo() []string {
@@ -22,7 +31,7 @@ func (srq *SignRequest) Validate() error {
 		return errInvalidInput
 	}
 
-	// default of 8 hours
+	// default of 1 year
 	if srq.TTL <= 0 || srq.TTL > (24*365*100*3600) {
 		srq.TTL = uint64(24 * 365 * 1 * 3600)
 	}
@@ -31,9 +40,14 @@ func (srq *SignRequest) Validate() error {
 
 }
 
+type ChallengeRequest struct {
+	Role string `params:"role"`
+}
+
 type RevokeRequest struct {
-	Role  string `params:"role"`
-	KeyId string `params:"keyid"`
+	Role   string `query:"-" params:"role"`
+	KeyId  string `query:"-" params:"keyid"`
+	Reason string `query:"reason"`
 }
 
 func (rr RevokeRequest) Validate() error {
@@ -44,6 +58,51 @@ func (rr RevokeRequest) Validate() error {
 	return nil
 }
 
+// certificate statuses to list by
+const (
+	StatusValid   = "valid"
+	StatusExpired = "expired"
+	StatusRevoked = "revoked"
+	StatusAll     = "all"
+)
+
+type ListRequest struct {
+	Role string `query:"-" params:"role"`
+	// substring of key id or principal
+	Query  string `query:"q"`
+	Status string `query:"status"`
+}
+
+func (lr *ListRequest) Validate() error {
+	switch lr.Status {
+	case "":
+		lr.Status = StatusAll
+	case StatusValid, StatusExpired, StatusRevoked, StatusAll:
+	default:
+		return errInvalidInput
+	}
+
+	return nil
+}
+
+// certificate along with what's inside and why it's revoked
+type CertInfo struct {
+	*model.Cert
+	Serial     uint64            `json:"serial,string"`
+	Principals []string          `json:"principals"`
+	Status     string            `json:"status"`
+	Revocation *model.Revocation `json:"revocation,omitempty"`
+}
+
+// what the present KRL revokes
+type KRLContents struct {
+	Version     uint64    `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Size        int       `json:"size"`
+	KeyIds      []string  `json:"key_ids"`
+	Serials     []string  `json:"serials"`
+}
+
 func NewCertAsCommonResp(cert model.Cert) *controller.CommonResp {
 	return &controller.CommonResp{
 		Code:   0,
@@ -51,3 +110,17 @@ func NewCertAsCommonResp(cert model.Cert) *controller.CommonResp {
 		Data:   cert,
 	}
 }
+
+// outcome of each certificate of an import, in order of the body
+type ImportResult struct {
+	KeyId   string `json:"id,omitempty"`
+	Revoked bool   `json:"revoked"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type KRLImportResp struct {
+	*model.ImportedKRL
+	// key ids of stored certificates revoked by it
+	Revoked []string `json:"revoked"`
+}
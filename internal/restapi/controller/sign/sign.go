@@ -1,14 +1,13 @@
 package sign
 
 import (
-	"log"
-
-	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
 	"github.com/0w0mewo/ssh_cert_ca/internal/model"
 	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/keyauth/v2"
 )
 
 func init() {
@@ -16,65 +15,62 @@ func init() {
 }
 
 type Router struct {
-	userca *service.SSHCertCAService
-	hostca *service.SSHCertCAService
+	userca     *service.SSHCertCAService
+	hostca     *service.SSHCertCAService
+	challenges *verify.ChallengeStore
+	approvals  *service.ApprovalService
 }
 
 func (r *Router) RegisterToPath(attchedTo *fiber.App) {
-	var err error
+	a := app.Get()
+
 	if r.userca == nil {
-		r.userca, err = service.NewSSHCertCAService(config.Cfg.DBconfig.Driver, config.Cfg.DBconfig.DSN,
-			config.Cfg.UserCA.PrivateKeyPath, "", model.CerTypeUser)
-		if err != nil {
-			panic(err)
-		}
+		r.userca = a.UserCA
 	}
 
 	if r.hostca == nil {
-		r.hostca, err = service.NewSSHCertCAService(config.Cfg.DBconfig.Driver, config.Cfg.DBconfig.DSN,
-			config.Cfg.HostCA.PrivateKeyPath, "", model.CertTypeHost)
-		if err != nil {
-			panic(err)
-		}
+		r.hostca = a.HostCA
 	}
 
-	grp := attchedTo.Group("/ca")
+	if r.challenges == nil {
+		r.challenges = a.Challenges
+	}
+
+	if r.approvals == nil {
+		r.approvals = a.Approvals
+	}
+
+	v1 := attchedTo.Group("/v1/cas")
 
-	grp.Use(keyauth.New(keyauth.Config{
-		Validator: func(c *fiber.Ctx, s string) (bool, error) {
-			if s == config.Cfg.AuthKey {
-				return true, nil
-			}
-
-			return false, errInvalidAuthKey
-		},
-		SuccessHandler: func(c *fiber.Ctx) error {
-			log.Printf("auth success from %s: %s %s", c.IP(), c.Method(), c.Path())
-
-			return c.Next()
-		},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			log.Printf("auth fail from %s: %s %s", c.IP(), c.Method(), c.Path())
-
-			return c.JSON(controller.CommonResp{
-				Code:   -1,
-				ErrMsg: err.Error(),
-				Data:   nil,
-			})
-		},
-	}))
+	v1.Use(a.Auth.Middleware())
 
 	// routes
 	{
-		grp.Post("/sign/:role", r.Sign)
-		grp.Get("/capubkey/:role", r.GetCAPublickey)
-		grp.Delete("/revoke/:role/:keyid", r.Revoke)
-		grp.Get("/getrevoked/:role", r.GetRevoked)
+		v1.Post("/:role/challenges", auth.RequireScope(model.ScopeSign), r.Challenge)
+		v1.Post("/:role/certificates", auth.RequireScope(model.ScopeSign), r.Sign)
+		v1.Get("/:role/certificates", auth.RequireScope(model.ScopeRead), r.ListCerts)
+		v1.Delete("/:role/certificates/:keyid", auth.RequireScope(model.ScopeRevoke), r.Revoke)
+		v1.Get("/:role/public-key", auth.RequireScope(model.ScopeRead), r.GetCAPublickey)
+		v1.Get("/:role/krl", auth.RequireScope(model.ScopeRead), r.GetRevoked)
+		v1.Get("/:role/krl/contents", auth.RequireScope(model.ScopeRead), r.GetRevokedContents)
+		v1.Post("/:role/certificates/import", auth.RequireScope(model.ScopeAdmin), r.ImportCerts)
+		v1.Post("/:role/krl/import", auth.RequireScope(model.ScopeAdmin), r.ImportKRL)
 	}
 
-}
+	grp := attchedTo.Group("/ca")
+
+	grp.Use(a.Auth.Middleware())
+
+	// legacy routes, kept until sunset
+	{
+		grp.Post("/challenge/:role", controller.Deprecated("/v1/cas/:role/challenges"), auth.RequireScope(model.ScopeSign), r.Challenge)
+		grp.Post("/sign/:role", controller.Deprecated("/v1/cas/:role/certificates"), auth.RequireScope(model.ScopeSign), r.Sign)
+		grp.Get("/capubkey/:role", controller.Deprecated("/v1/cas/:role/public-key"), auth.RequireScope(model.ScopeRead), r.GetCAPublickey)
+		grp.Delete("/revoke/:role/:keyid", controller.Deprecated("/v1/cas/:role/certificates/:keyid"), auth.RequireScope(model.ScopeRevoke), r.Revoke)
+		grp.Get("/getrevoked/:role", controller.Deprecated("/v1/cas/:role/krl"), auth.RequireScope(model.ScopeRead), r.GetRevoked)
+	}
 
-func (r *Router) Close() {
-	r.hostca.Stop()
-	r.userca.Stop()
 }
+
+// CA services are owned by app and closed along with it
+func (r *Router) Close() {}
@@ -0,0 +1,6 @@
+package tokens
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errMissingName = errs.New(errs.CodeInvalidInput, "missing token name")
+var errUnknownScope = errs.New(errs.CodeInvalidInput, "unknown scope")
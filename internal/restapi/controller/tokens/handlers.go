@@ -0,0 +1,62 @@
+package tokens
+
+import (
+	"log"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (r *Router) List(c *fiber.Ctx) error {
+	tokens, err := r.app.Auth.ListTokens()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(controller.NewCommonRespWithData(tokens))
+}
+
+// issue a new token, its secret is returned once and never stored
+func (r *Router) Create(c *fiber.Ctx) error {
+	var req CreateRequest
+	err := c.BodyParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	secret, t, err := r.app.Auth.CreateToken(req.Name, req.Scopes, req.Lifetime())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("token %s (%s) created by %s", t.Id, t.Scopes, auth.TokenFromCtx(c).Name)
+
+	return c.JSON(controller.NewCommonRespWithData(&CreateResp{Token: t, Secret: secret}))
+}
+
+// token of the caller, e.g. for clients to find out what they may do
+func (r *Router) Self(c *fiber.Ctx) error {
+	return c.JSON(controller.NewCommonRespWithData(auth.TokenFromCtx(c)))
+}
+
+func (r *Router) Delete(c *fiber.Ctx) error {
+	var req DeleteRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	err = r.app.Auth.DeleteToken(req.Id)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("token %s deleted by %s", req.Id, auth.TokenFromCtx(c).Name)
+
+	return c.JSON(controller.NewCommonRespWithData(nil))
+}
@@ -0,0 +1,43 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+type CreateRequest struct {
+	Name   string `json:"name"`
+	Scopes string `json:"scopes"` // comma separated, sign and read by default
+	TTL    uint64 `json:"ttl"`    // seconds, never expire if 0
+}
+
+func (cr *CreateRequest) Validate() error {
+	if cr.Name == "" {
+		return errMissingName
+	}
+
+	if cr.Scopes == "" {
+		cr.Scopes = model.DefaultScope
+	}
+
+	if !model.ValidScopes(cr.Scopes) {
+		return errUnknownScope
+	}
+
+	return nil
+}
+
+func (cr CreateRequest) Lifetime() time.Duration {
+	return time.Duration(cr.TTL) * time.Second
+}
+
+type DeleteRequest struct {
+	Id string `params:"id"`
+}
+
+// the secret is only shown here
+type CreateResp struct {
+	model.Token
+	Secret string `json:"secret"`
+}
@@ -0,0 +1,36 @@
+package tokens
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router manages API tokens, as the token subcommand does offline
+type Router struct {
+	app *app.App
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	v1 := attchedTo.Group("/v1/tokens")
+
+	v1.Use(r.app.Auth.Middleware())
+
+	// routes
+	{
+		v1.Get("/", auth.RequireScope(model.ScopeAdmin), r.List)
+		v1.Post("/", auth.RequireScope(model.ScopeAdmin), r.Create)
+		v1.Get("/self", r.Self)
+		v1.Delete("/:id", auth.RequireScope(model.ScopeAdmin), r.Delete)
+	}
+}
+
+func (r *Router) Close() {}
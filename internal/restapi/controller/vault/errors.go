@@ -0,0 +1,15 @@
+package vault
+
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+
+var errUnknownRole = errs.New(errs.CodeNotFound, "unknown role")
+var errUnsupportedKeyType = errs.New(errs.CodeInvalidInput, "only roles of key_type ca are supported")
+var errInvalidTTL = errs.New(errs.CodeInvalidInput, "invalid ttl, expect duration like 8h or number of seconds")
+var errTTLTooLong = errs.New(errs.CodeInvalidInput, "ttl is larger than max_ttl of role")
+var errEmptyPrincipals = errs.New(errs.CodeNoPrincipals, "empty valid principals not allowed by role")
+var errCertTypeNotAllowed = errs.New(errs.CodePolicyDenied, "certificate type is not allowed by role")
+var errPrincipalNotAllowed = errs.New(errs.CodePolicyDenied, "principal is not allowed by role")
+var errExtensionNotAllowed = errs.New(errs.CodePolicyDenied, "extension is not allowed by role")
+var errCriticalOptionNotAllowed = errs.New(errs.CodePolicyDenied, "critical option is not allowed by role")
+var errKeyIdNotAllowed = errs.New(errs.CodePolicyDenied, "setting key_id is not allowed by role")
+var errProofRequired = errs.New(errs.CodeInvalidSignature, "the CA requires proof of possession, sign through /v1/cas/:role/certificates")
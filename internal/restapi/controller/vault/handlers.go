@@ -0,0 +1,187 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// user CA public key in authorized_keys format, what sshd of Vault users trusts
+func (r *Router) PublicKey(c *fiber.Ctx) error {
+	return c.SendString(r.app.UserCA.PublicKeyAsAuthKeyStr() + "\n")
+}
+
+func (r *Router) ConfigCA(c *fiber.Ctx) error {
+	return c.JSON(NewResp(&ConfigCAResp{PublicKey: r.app.UserCA.PublicKeyAsAuthKeyStr() + "\n"}))
+}
+
+// sign the public key within the limits of the role, by the CA of requested certificate type
+func (r *Router) Sign(c *fiber.Ctx) error {
+	rl, err := r.role(c)
+	if err != nil {
+		return err
+	}
+
+	var req SignRequest
+	err = json.Unmarshal(c.Body(), &req)
+	if err != nil {
+		return controller.ErrBadRequest.Wrap(err)
+	}
+
+	ct, err := rl.certType(req.CertType)
+	if err != nil {
+		return err
+	}
+
+	principals, err := rl.principals(ct, req.ValidPrincipals)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := rl.ttl(time.Duration(req.TTL))
+	if err != nil {
+		return err
+	}
+
+	opts, err := rl.options(req.Extensions, req.CriticalOptions)
+	if err != nil {
+		return err
+	}
+
+	if req.KeyId != "" && !rl.AllowUserKeyIds {
+		return errKeyIdNotAllowed
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey([]byte(req.PublicKey))
+	if err != nil {
+		return err
+	}
+
+	signer, err := r.app.CAByRole(ct)
+	if err != nil {
+		return err
+	}
+
+	// Vault clients have no way to sign a nonce
+	if requireSignedNonce(ct) {
+		return errProofRequired
+	}
+
+	sreq := &service.SignRequest{
+		PublicKey:   pubkey,
+		KeyId:       req.KeyId,
+		Requester:   auth.TokenFromCtx(c).Name,
+		RequesterId: auth.TokenFromCtx(c).Id,
+		ClientIP:    c.IP(),
+		Principals:  principals,
+		TTL:         ttl,
+		Options:     opts,
+	}
+
+	cert, err := signer.Issue(sreq)
+	if errors.Is(err, policy.ErrApprovalRequired) {
+		cr, err := r.app.Approvals.Submit(ct, sreq)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("request %s for %s by %s is pending approval", cr.Id, cr.Principals, cr.Requester)
+
+		// Vault has no such thing, tell it as a warning
+		return c.Status(fiber.StatusAccepted).JSON(NewResp(nil,
+			fmt.Sprintf("request %s is pending approval, collect the certificate from /v1/requests/%s once approved", cr.Id, cr.Id)))
+	}
+	if err != nil {
+		return err
+	}
+
+	parsed, err := utils.ParseSSHCert([]byte(cert.Content))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(NewResp(&SignResp{
+		SerialNumber: strconv.FormatUint(parsed.Serial, 16),
+		SignedKey:    cert.Content + "\n",
+	}))
+}
+
+// names of roles, Vault clients list by GET with list=true
+func (r *Router) ListRoles(c *fiber.Ctx) error {
+	keys := make([]string, 0, len(r.roles))
+	for name := range r.roles {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	return c.JSON(NewResp(&ListResp{Keys: keys}))
+}
+
+func (r *Router) ReadRole(c *fiber.Ctx) error {
+	rl, err := r.role(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(NewResp(rl.VaultRole))
+}
+
+func (r *Router) role(c *fiber.Ctx) (role, error) {
+	var req RoleRequest
+	err := c.ParamsParser(&req)
+	if err != nil {
+		return role{}, controller.ErrBadRequest.Wrap(err)
+	}
+
+	rl, ok := r.roles[req.Role]
+	if !ok || rl == nil {
+		return role{}, errUnknownRole
+	}
+
+	return role{rl}, nil
+}
+
+// Vault clients send the token in X-Vault-Token header
+func vaultToken(c *fiber.Ctx) error {
+	if t := c.Get(HeaderVaultToken); t != "" && c.Get(fiber.HeaderAuthorization) == "" {
+		c.Request().Header.Set(fiber.HeaderAuthorization, "Bearer "+t)
+	}
+
+	return c.Next()
+}
+
+// errors in the shape of Vault, {"errors": ["..."]}
+func vaultErrors(c *fiber.Ctx) error {
+	err := c.Next()
+	if err == nil {
+		return nil
+	}
+
+	p := controller.NewProblem(err, c.Path())
+	if p.Status >= fiber.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+	}
+
+	return c.Status(p.Status).JSON(&ErrorResp{Errors: []string{p.Detail}})
+}
+
+func requireSignedNonce(role model.RoleType) bool {
+	if role == model.CertTypeHost {
+		return config.Cfg.HostCA.RequireSignedNonce
+	}
+
+	return config.Cfg.UserCA.RequireSignedNonce
+}
@@ -0,0 +1,103 @@
+package vault
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const HeaderVaultToken = "X-Vault-Token"
+
+type RoleRequest struct {
+	Role string `params:"role"`
+}
+
+// body of sign requests, Vault clients may send it without content type
+type SignRequest struct {
+	PublicKey       string            `json:"public_key"`
+	ValidPrincipals string            `json:"valid_principals"` // comma separated
+	TTL             TTL               `json:"ttl"`
+	CertType        string            `json:"cert_type"` // user by default
+	KeyId           string            `json:"key_id"`
+	CriticalOptions map[string]string `json:"critical_options"`
+	Extensions      map[string]string `json:"extensions"`
+}
+
+// number of seconds, or a string of seconds or duration like 8h
+type TTL time.Duration
+
+func (t *TTL) UnmarshalJSON(b []byte) error {
+	var secs uint64
+	if err := json.Unmarshal(b, &secs); err == nil {
+		*t = TTL(time.Duration(secs) * time.Second)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errInvalidTTL
+	}
+
+	if s == "" {
+		*t = 0
+		return nil
+	}
+
+	if secs, err := strconv.ParseUint(s, 10, 64); err == nil {
+		*t = TTL(time.Duration(secs) * time.Second)
+		return nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return errInvalidTTL
+	}
+
+	*t = TTL(d)
+
+	return nil
+}
+
+type SignResp struct {
+	SerialNumber string `json:"serial_number"` // hex
+	SignedKey    string `json:"signed_key"`
+}
+
+type ListResp struct {
+	Keys []string `json:"keys"`
+}
+
+type ConfigCAResp struct {
+	PublicKey string `json:"public_key"`
+}
+
+// response envelope of Vault
+type Resp struct {
+	RequestId     string   `json:"request_id"`
+	LeaseId       string   `json:"lease_id"`
+	Renewable     bool     `json:"renewable"`
+	LeaseDuration int      `json:"lease_duration"`
+	Data          any      `json:"data"`
+	WrapInfo      any      `json:"wrap_info"`
+	Warnings      []string `json:"warnings"`
+	Auth          any      `json:"auth"`
+}
+
+func NewResp(data any, warnings ...string) *Resp {
+	resp := &Resp{
+		RequestId: uuid.NewString(),
+		Data:      data,
+	}
+	if len(warnings) > 0 {
+		resp.Warnings = warnings
+	}
+
+	return resp
+}
+
+// error body of Vault
+type ErrorResp struct {
+	Errors []string `json:"errors"`
+}
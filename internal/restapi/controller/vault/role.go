@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+)
+
+// lifetime of certificates if neither the request nor the role sets it, default lease of Vault
+const defaultTTL = 768 * time.Hour
+
+// translates a sign request to what the CA service takes, within the limits of the role
+type role struct {
+	*config.VaultRole
+}
+
+func (r role) certType(certType string) (model.RoleType, error) {
+	if r.KeyType != "" && r.KeyType != "ca" {
+		return 0, errUnsupportedKeyType
+	}
+
+	if certType == "" {
+		certType = "user"
+	}
+
+	ct, err := model.ParseCertType(certType)
+	if err != nil {
+		return 0, err
+	}
+
+	if (ct == model.CerTypeUser && !r.AllowUserCertificates) || (ct == model.CertTypeHost && !r.AllowHostCertificates) {
+		return 0, errCertTypeNotAllowed
+	}
+
+	return ct, nil
+}
+
+// default user is signed for if no principal is requested, host certificates always need them
+func (r role) principals(ct model.RoleType, requested string) ([]string, error) {
+	principals := splitList(requested)
+	if len(principals) == 0 && ct == model.CerTypeUser && r.DefaultUser != "" {
+		principals = []string{r.DefaultUser}
+	}
+
+	if len(principals) == 0 {
+		return nil, errEmptyPrincipals
+	}
+
+	for _, p := range principals {
+		ok := r.allowedHost(p)
+		if ct == model.CerTypeUser {
+			ok = p == r.DefaultUser || inList(r.AllowedUsers, p)
+		}
+
+		if !ok {
+			return nil, errPrincipalNotAllowed
+		}
+	}
+
+	return principals, nil
+}
+
+func (r role) allowedHost(host string) bool {
+	for _, d := range splitList(r.AllowedDomains) {
+		if d == "*" || (r.AllowBareDomains && host == d) || (r.AllowSubdomains && strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r role) ttl(requested time.Duration) (time.Duration, error) {
+	ttl := requested
+	if ttl <= 0 {
+		ttl = time.Duration(r.TTL) * time.Second
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	if r.MaxTTL > 0 && ttl > time.Duration(r.MaxTTL)*time.Second {
+		return 0, errTTLTooLong
+	}
+
+	return ttl, nil
+}
+
+// requested extensions and critical options must be allowed, defaults of the role are used otherwise.
+// nil if there are neither, so that defaults of the CA are used
+func (r role) options(extensions, criticalOptions map[string]string) (*ca.CertOptions, error) {
+	for k := range extensions {
+		if !inList(r.AllowedExtensions, k) {
+			return nil, errExtensionNotAllowed
+		}
+	}
+
+	for k := range criticalOptions {
+		if !inList(r.AllowedCriticalOptions, k) {
+			return nil, errCriticalOptionNotAllowed
+		}
+	}
+
+	if extensions == nil {
+		extensions = r.DefaultExtensions
+	}
+	if criticalOptions == nil {
+		criticalOptions = r.DefaultCriticalOptions
+	}
+
+	if extensions == nil && criticalOptions == nil {
+		return nil, nil
+	}
+
+	return &ca.CertOptions{
+		Extensions:      extensions,
+		CriticalOptions: criticalOptions,
+	}, nil
+}
+
+func splitList(list string) []string {
+	res := make([]string, 0)
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// comma separated list, * allows anything
+func inList(list, v string) bool {
+	for _, allowed := range splitList(list) {
+		if allowed == "*" || allowed == v {
+			return true
+		}
+	}
+
+	return false
+}
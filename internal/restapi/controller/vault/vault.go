@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+)
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router speaks the API of Vault SSH secrets engine mounted at ssh, so that its clients can be pointed here
+type Router struct {
+	app   *app.App
+	roles map[string]*config.VaultRole
+}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	r.app = app.Get()
+
+	if config.Cfg.Vault == nil {
+		return
+	}
+	r.roles = config.Cfg.Vault.Roles
+
+	grp := attchedTo.Group("/v1/ssh", vaultErrors)
+
+	authenticate := r.app.Auth.Middleware()
+	withScope := func(scope string, h fiber.Handler) []fiber.Handler {
+		return []fiber.Handler{vaultToken, authenticate, auth.RequireScope(scope), h}
+	}
+
+	// routes
+	{
+		// public as Vault does
+		grp.Get("/public_key", r.PublicKey)
+		grp.Get("/config/ca", withScope(model.ScopeRead, r.ConfigCA)...)
+
+		// vault write uses PUT
+		grp.Post("/sign/:role", withScope(model.ScopeSign, r.Sign)...)
+		grp.Put("/sign/:role", withScope(model.ScopeSign, r.Sign)...)
+
+		grp.Get("/roles", withScope(model.ScopeRead, r.ListRoles)...)
+		grp.Get("/roles/:role", withScope(model.ScopeRead, r.ReadRole)...)
+	}
+}
+
+func (r *Router) Close() {}
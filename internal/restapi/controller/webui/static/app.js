@@ -0,0 +1,446 @@
+'use strict';
+
+// the token only lives as long as the tab
+const tokenKey = 'ssh-cert-ca-token';
+
+const $ = (id) => document.getElementById(id);
+
+let self = null;
+
+function token() {
+  return sessionStorage.getItem(tokenKey);
+}
+
+function hasScope(scope) {
+  if (!self) {
+    return false;
+  }
+  const scopes = self.scopes.split(',');
+  return scopes.includes('admin') || scopes.includes(scope);
+}
+
+// call the REST API, returns data of the envelope or throws with detail of the problem
+async function api(method, path, body) {
+  const opts = { method, headers: { Authorization: 'Bearer ' + token() } };
+  if (body !== undefined) {
+    opts.headers['Content-Type'] = 'application/json';
+    opts.body = JSON.stringify(body);
+  }
+
+  const resp = await fetch(path, opts);
+  let payload = null;
+  try {
+    payload = await resp.json();
+  } catch (e) {
+    // not json
+  }
+
+  if (!resp.ok) {
+    if (resp.status === 401) {
+      logout();
+    }
+    const msg = payload && (payload.detail || payload.title || payload.errMsg);
+    throw new Error(msg || resp.status + ' ' + resp.statusText);
+  }
+
+  return payload ? payload.data : null;
+}
+
+function showError(err) {
+  const el = $('error');
+  if (!err) {
+    el.hidden = true;
+    return;
+  }
+  el.textContent = err.message || String(err);
+  el.hidden = false;
+}
+
+// run an action, reporting its failure
+async function run(fn) {
+  showError(null);
+  try {
+    await fn();
+  } catch (e) {
+    showError(e);
+  }
+}
+
+function el(tag, text, cls) {
+  const e = document.createElement(tag);
+  if (text !== undefined && text !== null) {
+    e.textContent = text;
+  }
+  if (cls) {
+    e.className = cls;
+  }
+  return e;
+}
+
+function row(cells) {
+  const tr = el('tr');
+  for (const c of cells) {
+    const td = el('td');
+    if (c instanceof Node) {
+      td.appendChild(c);
+    } else {
+      td.textContent = c === undefined || c === null ? '' : c;
+    }
+    tr.appendChild(td);
+  }
+  return tr;
+}
+
+function button(text, onclick) {
+  const b = el('button', text);
+  b.type = 'button';
+  b.addEventListener('click', () => run(onclick));
+  return b;
+}
+
+function fmtTime(s) {
+  if (!s || s.startsWith('0001-')) {
+    return 'never';
+  }
+  return new Date(s).toLocaleString();
+}
+
+function fmtTTL(secs) {
+  if (!secs) {
+    return 'any';
+  }
+  if (secs % 86400 === 0) {
+    return secs / 86400 + 'd';
+  }
+  if (secs % 3600 === 0) {
+    return secs / 3600 + 'h';
+  }
+  return secs + 's';
+}
+
+function splitList(s) {
+  return s.split(',').map((x) => x.trim()).filter((x) => x !== '');
+}
+
+// bar showing how much of the validity period has passed
+function timeline(start, end) {
+  const wrap = el('div');
+  const s = new Date(start).getTime();
+  const e = new Date(end).getTime();
+  const now = Date.now();
+  const frac = e > s ? Math.min(Math.max((now - s) / (e - s), 0), 1) : 1;
+
+  const bar = el('div', null, 'timeline');
+  if (now >= e) {
+    bar.classList.add('over');
+  } else if (e - now < 24 * 3600 * 1000) {
+    bar.classList.add('soon');
+  }
+  const elapsed = el('div', null, 'elapsed');
+  elapsed.style.width = (frac * 100).toFixed(1) + '%';
+  bar.appendChild(elapsed);
+
+  wrap.appendChild(bar);
+  wrap.appendChild(el('div', fmtTime(start) + ' – ' + fmtTime(end), 'small'));
+  return wrap;
+}
+
+// certificates
+
+async function loadCerts() {
+  const role = $('certs-role').value;
+  const params = new URLSearchParams({ status: $('certs-status').value });
+  if ($('certs-q').value) {
+    params.set('q', $('certs-q').value);
+  }
+
+  const certs = await api('GET', '/v1/cas/' + role + '/certificates?' + params);
+  const body = $('certs-body');
+  body.replaceChildren();
+
+  for (const c of certs || []) {
+    let status = el('span', c.status, 'status-' + c.status);
+    if (c.revocation) {
+      const s = el('div');
+      s.appendChild(status);
+      const who = c.revocation.revoked_by ? ' by ' + c.revocation.revoked_by : '';
+      s.appendChild(el('div', (c.revocation.reason || 'no reason') + who, 'small'));
+      status = s;
+    }
+
+    let action = '';
+    if (c.status !== 'revoked' && hasScope('revoke')) {
+      action = button('revoke', () => revokeCert(role, c.id));
+    }
+
+    body.appendChild(row([c.id, c.serial, (c.principals || []).join(', '), status, timeline(c.valid_start, c.valid_end), action]));
+  }
+}
+
+async function revokeCert(role, keyid) {
+  const reason = prompt('Revoke ' + keyid + '?\nReason:');
+  if (reason === null) {
+    return;
+  }
+
+  const params = new URLSearchParams();
+  if (reason) {
+    params.set('reason', reason);
+  }
+  await api('DELETE', '/v1/cas/' + role + '/certificates/' + encodeURIComponent(keyid) + '?' + params);
+  await loadCerts();
+}
+
+// KRL
+
+async function loadKRL() {
+  const role = $('krl-role').value;
+  const [krl, revoked] = await Promise.all([
+    api('GET', '/v1/cas/' + role + '/krl/contents'),
+    api('GET', '/v1/cas/' + role + '/certificates?status=revoked'),
+  ]);
+
+  const info = $('krl-info');
+  info.replaceChildren();
+  for (const [k, v] of [
+    ['version', krl.version],
+    ['generated at', fmtTime(krl.generated_at)],
+    ['size', krl.size + ' bytes'],
+    ['revoked serials', (krl.serials || []).length],
+  ]) {
+    info.appendChild(el('dt', k));
+    info.appendChild(el('dd', v));
+  }
+
+  const records = {};
+  for (const c of revoked || []) {
+    records[c.id] = c.revocation || {};
+  }
+
+  const body = $('krl-body');
+  body.replaceChildren();
+  for (const id of krl.key_ids || []) {
+    const r = records[id] || {};
+    body.appendChild(row([id, r.reason, r.revoked_by, r.revoked_at ? fmtTime(r.revoked_at) : '']));
+  }
+}
+
+// approval requests
+
+async function loadRequests() {
+  const status = $('requests-status').value;
+  const reqs = await api('GET', '/v1/requests/?status=' + status);
+  const body = $('requests-body');
+  body.replaceChildren();
+
+  for (const r of reqs || []) {
+    const approvers = r.approvers || [];
+    const actions = el('span');
+    if (r.status === 'pending') {
+      actions.appendChild(button('approve', () => decide(r.id, 'approve')));
+      actions.appendChild(button('deny', () => decide(r.id, 'deny')));
+    }
+
+    const role = r.type === 0 ? 'user' : 'host';
+    body.appendChild(row([
+      r.id, role, r.requester, r.principals, fmtTTL(r.ttl),
+      approvers.length + '/' + r.required + (approvers.length ? ' (' + approvers.join(', ') + ')' : ''),
+      el('span', r.status, 'status-' + r.status), actions,
+    ]));
+  }
+}
+
+async function decide(id, decision) {
+  if (!confirm(decision + ' request ' + id + '?')) {
+    return;
+  }
+  await api('POST', '/v1/requests/' + encodeURIComponent(id) + '/' + decision);
+  await loadRequests();
+}
+
+// tokens
+
+async function loadTokens() {
+  const tokens = await api('GET', '/v1/tokens/');
+  const body = $('tokens-body');
+  body.replaceChildren();
+
+  for (const t of tokens || []) {
+    let action = '';
+    if (!self || t.id !== self.id) {
+      action = button('delete', () => deleteToken(t));
+    }
+    body.appendChild(row([t.id, t.name, t.scopes, fmtTime(t.created_at), fmtTime(t.expires_at), action]));
+  }
+}
+
+async function createToken() {
+  const t = await api('POST', '/v1/tokens/', {
+    name: $('tokens-name').value,
+    scopes: $('tokens-scopes').value,
+    ttl: Number($('tokens-ttl').value || 0),
+  });
+
+  const secret = $('tokens-secret');
+  secret.textContent = 'token ' + t.name + ' created, copy it now, it will not be shown again: ' + t.secret;
+  secret.hidden = false;
+  $('tokens-form').reset();
+  await loadTokens();
+}
+
+async function deleteToken(t) {
+  if (!confirm('delete token ' + t.name + ' (' + t.id + ')?')) {
+    return;
+  }
+  await api('DELETE', '/v1/tokens/' + encodeURIComponent(t.id));
+  await loadTokens();
+}
+
+// policies
+
+function field(label, value, type) {
+  const l = el('label', label + ' ');
+  const input = el('input');
+  input.type = type || 'text';
+  input.value = value === undefined || value === null ? '' : value;
+  l.appendChild(input);
+  return [l, input];
+}
+
+function policyForm(role, policy) {
+  const fs = el('fieldset');
+  fs.appendChild(el('legend', role + ' CA'));
+
+  const a = policy.approval || {};
+  const [pl, principals] = field('sensitive principals', (a.sensitive_principals || []).join(','));
+  const [tl, maxTTL] = field('max ttl without approval (seconds, 0 any)', a.max_ttl || 0, 'number');
+  const [rl, required] = field('required approvals', a.required || 1, 'number');
+  const [al, approvers] = field('approver token ids (empty: any token with approve scope)', (a.approvers || []).join(','));
+  const [el_, expiry] = field('pending request expiry (seconds)', a.expiry || 0, 'number');
+  for (const l of [pl, tl, rl, al, el_]) {
+    fs.appendChild(l);
+  }
+
+  if (!policy.approval) {
+    fs.appendChild(el('p', 'no request needs approval', 'hint'));
+  }
+
+  fs.appendChild(button('save', async () => {
+    await api('PUT', '/v1/policies/' + role + '/approval', {
+      sensitive_principals: splitList(principals.value),
+      max_ttl: Number(maxTTL.value || 0),
+      required: Number(required.value || 0),
+      approvers: splitList(approvers.value),
+      expiry: Number(expiry.value || 0),
+    });
+    await loadPolicies();
+  }));
+
+  if (policy.approval) {
+    fs.appendChild(button('remove approval', async () => {
+      if (!confirm('requests of the ' + role + ' CA will no longer need approval, continue?')) {
+        return;
+      }
+      await api('DELETE', '/v1/policies/' + role + '/approval');
+      await loadPolicies();
+    }));
+  }
+
+  if (policy.groups) {
+    fs.appendChild(el('h4', 'LDAP groups (read only)'));
+    const table = el('table');
+    table.appendChild(row(['group', 'principals', 'extensions']));
+    for (const [g, m] of Object.entries(policy.groups)) {
+      table.appendChild(row([g, (m.principals || []).join(', '), Object.keys(m.extensions || {}).join(', ')]));
+    }
+    fs.appendChild(table);
+  }
+
+  return fs;
+}
+
+async function loadPolicies() {
+  const policies = await api('GET', '/v1/policies/');
+  const body = $('policies-body');
+  body.replaceChildren(policyForm('user', policies.user), policyForm('host', policies.host));
+}
+
+// navigation
+
+const loaders = {
+  certs: loadCerts,
+  krl: loadKRL,
+  requests: loadRequests,
+  tokens: loadTokens,
+  policies: loadPolicies,
+};
+
+// tabs not usable with scopes of the token
+const tabScopes = {
+  certs: 'read',
+  krl: 'read',
+  requests: 'approve',
+  tokens: 'admin',
+  policies: 'admin',
+};
+
+function showTab(name) {
+  for (const b of document.querySelectorAll('nav button')) {
+    b.classList.toggle('active', b.dataset.tab === name);
+  }
+  for (const s of document.querySelectorAll('.tab')) {
+    s.hidden = s.id !== 'tab-' + name;
+  }
+  run(loaders[name]);
+}
+
+async function start() {
+  self = await api('GET', '/v1/tokens/self');
+
+  $('whoami').textContent = self.name + ' (' + self.scopes + ')';
+  $('login').hidden = true;
+  $('main').hidden = false;
+  $('logout').hidden = false;
+
+  let first = null;
+  for (const b of document.querySelectorAll('nav button')) {
+    b.hidden = !hasScope(tabScopes[b.dataset.tab]);
+    if (!b.hidden && !first) {
+      first = b.dataset.tab;
+    }
+  }
+  if (first) {
+    showTab(first);
+  }
+}
+
+function logout() {
+  sessionStorage.removeItem(tokenKey);
+  self = null;
+  $('login').hidden = false;
+  $('main').hidden = true;
+  $('logout').hidden = true;
+  $('whoami').textContent = '';
+}
+
+$('login-form').addEventListener('submit', (ev) => {
+  ev.preventDefault();
+  sessionStorage.setItem(tokenKey, $('login-token').value);
+  $('login-token').value = '';
+  run(start);
+});
+
+$('logout').addEventListener('click', logout);
+
+for (const b of document.querySelectorAll('nav button')) {
+  b.addEventListener('click', () => showTab(b.dataset.tab));
+}
+
+$('certs-form').addEventListener('submit', (ev) => { ev.preventDefault(); run(loadCerts); });
+$('krl-form').addEventListener('submit', (ev) => { ev.preventDefault(); run(loadKRL); });
+$('requests-form').addEventListener('submit', (ev) => { ev.preventDefault(); run(loadRequests); });
+$('tokens-form').addEventListener('submit', (ev) => { ev.preventDefault(); run(createToken); });
+
+if (token()) {
+  run(start);
+}
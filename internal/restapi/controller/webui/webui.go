@@ -0,0 +1,45 @@
+package webui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+)
+
+// UIPath is where the operator UI is served
+const UIPath = "/ui"
+
+//go:embed static
+var static embed.FS
+
+func init() {
+	controller.RegisterController(&Router{})
+}
+
+// Router serves the operator web UI, a static page talking to the REST API with an API token
+type Router struct{}
+
+func (r *Router) RegisterToPath(attchedTo *fiber.App) {
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+
+	attchedTo.Use(UIPath, func(c *fiber.Ctx) error {
+		// the page only loads its own assets and talks to its own origin
+		c.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		c.Set("X-Content-Type-Options", "nosniff")
+		c.Set("Referrer-Policy", "no-referrer")
+		return c.Next()
+	}, filesystem.New(filesystem.Config{
+		Root:   http.FS(root),
+		Index:  "index.html",
+		MaxAge: 300,
+	}))
+}
+
+func (r *Router) Close() {}
@@ -1,5 +1,17 @@
 package restapi
 
 import (
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/approval"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/backups"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/bootstrap"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/breakglass"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/events"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/inspect"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/login"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/policies"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/renew"
 	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/sign"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/tokens"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/vault"
+	_ "github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/webui"
 )
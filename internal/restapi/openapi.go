@@ -0,0 +1,127 @@
+package restapi
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/vault"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/openapi"
+	"github.com/gofiber/fiber/v2"
+)
+
+const OpenAPIPath = "/openapi.json"
+
+const bearerPrefix = "Bearer "
+
+// OpenAPI document of every route, kept in sync by checkSpecCoverage in tests
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func loadSpec() *openapi.Document {
+	doc, err := openapi.Parse(openAPISpec)
+	if err != nil {
+		panic(fmt.Errorf("parse embedded openapi.json: %w", err))
+	}
+
+	return doc
+}
+
+// reject requests not matching the parameters and body described by the document.
+// operations needing credentials are only checked for callers holding valid ones, others
+// are passed on to be refused by authentication, so the document is not revealed to them
+func validateRequest(doc *openapi.Document, authn *auth.Authenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		op, _ := doc.Find(c.Method(), c.Path())
+		if op != nil && doc.Secured(op) && !hasValidCredential(c, authn) {
+			return c.Next()
+		}
+
+		err := doc.Validate(&openapi.Request{
+			Method:      c.Method(),
+			Path:        c.Path(),
+			Query:       queryValues(c),
+			ContentType: string(c.Request().Header.ContentType()),
+			Body:        c.Body(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return c.Next()
+	}
+}
+
+// bearer token, or X-Vault-Token of Vault clients, accepted by authenticator
+func hasValidCredential(c *fiber.Ctx, authn *auth.Authenticator) bool {
+	secret := c.Get(vault.HeaderVaultToken)
+
+	h := c.Get(fiber.HeaderAuthorization)
+	if len(h) > len(bearerPrefix) && strings.EqualFold(h[:len(bearerPrefix)], bearerPrefix) {
+		secret = strings.TrimSpace(h[len(bearerPrefix):])
+	}
+
+	_, err := authn.Authenticate(secret)
+
+	return err == nil
+}
+
+func queryValues(c *fiber.Ctx) map[string][]string {
+	res := make(map[string][]string)
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		res[string(k)] = append(res[string(k)], string(v))
+	})
+
+	return res
+}
+
+// every registered route must be described by the document, so the document
+// can't silently fall behind handlers
+func checkSpecCoverage(router *fiber.App, doc *openapi.Document) error {
+	described := make(map[string]bool)
+	for _, r := range doc.Routes() {
+		described[routeKey(r.Method, r.Path)] = true
+	}
+
+	missing := make([]string, 0)
+	for _, r := range router.GetRoutes(true) {
+		// HEAD is added along with every GET route
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+
+		if !described[routeKey(r.Method, fiberPathToSpec(r.Path))] {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("routes missing from openapi.json: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// /v1/cas/:role/certificates/:keyid -> /v1/cas/{role}/certificates/{keyid}
+func fiberPathToSpec(path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		if strings.HasPrefix(s, ":") {
+			segs[i] = "{" + strings.TrimSuffix(s[1:], "?") + "}"
+		}
+	}
+
+	return strings.Join(segs, "/")
+}
+
+// paths are compared as the router does, ignoring case and trailing slash
+func routeKey(method, path string) string {
+	path = strings.TrimSuffix(strings.ToLower(path), "/")
+	if path == "" {
+		path = "/"
+	}
+
+	return method + " " + path
+}
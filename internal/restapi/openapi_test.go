@@ -0,0 +1,89 @@
+package restapi
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const testAuthKey = "test-auth-key"
+
+// controllers share the app instance, so the server is built once for all tests
+var testServer *ApiServer
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "restapi")
+	if err != nil {
+		panic(err)
+	}
+
+	// every optional controller is enabled, so all routes are registered
+	config.Cfg = &config.Config{
+		HostCA:    &config.CAConfig{PrivateKeyPath: filepath.Join(dir, "ca_host")},
+		UserCA:    &config.CAConfig{PrivateKeyPath: filepath.Join(dir, "ca_user")},
+		AuthKey:   testAuthKey,
+		DBconfig:  &config.DBConfig{Driver: "memory"},
+		Bootstrap: &config.BootstrapConfig{HostDomains: []string{"*"}},
+		// discovered on first use, never reached by these tests
+		OIDC:       &config.OIDCConfig{Issuer: "http://127.0.0.1:1", ClientID: "ssh-ca"},
+		BreakGlass: &config.BreakGlassConfig{Principal: "root"},
+		Vault: &config.VaultConfig{Roles: map[string]*config.VaultRole{
+			"users": {KeyType: "ca", AllowUserCertificates: true, AllowedUsers: "*"},
+		}},
+	}
+
+	testServer = NewApiServer()
+	testServer.init()
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestSpecCoverage(t *testing.T) {
+	err := checkSpecCoverage(testServer.router, loadSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// requests of the default limiter are few, so each case is a single request
+func TestValidateRequestAfterAuth(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		path        string
+		contentType string
+		header      map[string]string
+		status      int
+	}{
+		{"no credential", "/v1/cas/user/certificates", fiber.MIMEApplicationJSON, nil, fiber.StatusUnauthorized},
+		{"bearer", "/v1/cas/user/certificates", fiber.MIMEApplicationJSON,
+			map[string]string{fiber.HeaderAuthorization: "Bearer " + testAuthKey}, fiber.StatusBadRequest},
+		{"vault token", "/v1/ssh/sign/users", fiber.MIMEApplicationJSON,
+			map[string]string{"X-Vault-Token": testAuthKey}, fiber.StatusBadRequest},
+		// public operations are checked for everyone
+		{"public", "/oidc/login", fiber.MIMETextPlain, nil, fiber.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, tc.path, strings.NewReader(`{"pubkey": 1, "public_key": 1}`))
+			req.Header.Set(fiber.HeaderContentType, tc.contentType)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := testServer.router.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+		})
+	}
+}
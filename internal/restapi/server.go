@@ -1,15 +1,29 @@
 package restapi
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
 	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller/webui"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 )
 
+var errRateLimited = errs.New(errs.CodeRateLimited, "too many requests")
+
+// requests per client IP within the window, unless set in config
+const (
+	defaultRateLimit  = 30
+	defaultRateWindow = time.Minute
+)
+
 type ApiServer struct {
 	router *fiber.App
 }
@@ -32,7 +46,42 @@ func NewApiServer() *ApiServer {
 
 func (as *ApiServer) init() {
 	// middleware
-	as.router.Use(limiter.New(limiter.Config{}))
+	limit, window := defaultRateLimit, defaultRateWindow
+	if rl := config.Cfg.RateLimit; rl != nil {
+		if rl.Max > 0 {
+			limit = rl.Max
+		}
+		if rl.Window > 0 {
+			window = time.Duration(rl.Window) * time.Second
+		}
+	}
+
+	as.router.Use(limiter.New(limiter.Config{
+		Max:        limit,
+		Expiration: window,
+		LimitReached: func(c *fiber.Ctx) error {
+			return errRateLimited
+		},
+		// web UI assets and callers holding a valid token, e.g. the web UI polling pending requests, are not limited,
+		// so what is counted is mostly requests guessing tokens
+		Next: func(c *fiber.Ctx) bool {
+			return isUIPath(c.Path()) || exemptByController(c) || hasValidCredential(c, app.Get().Auth)
+		},
+	}))
+
+	// requests are checked against the API specification before reaching handlers
+	as.router.Use(validateRequest(loadSpec(), app.Get().Auth))
+
+	// error code catalogue, type of problem details points into it
+	as.router.Get(controller.ErrorCataloguePath, func(c *fiber.Ctx) error {
+		return c.JSON(controller.NewCommonRespWithData(errs.Catalogue()))
+	})
+
+	// API specification
+	as.router.Get(OpenAPIPath, func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(openAPISpec)
+	})
 
 	// register controllers
 	for c := range controller.GetController() {
@@ -41,6 +90,20 @@ func (as *ApiServer) init() {
 
 }
 
+func exemptByController(c *fiber.Ctx) bool {
+	for ctrl := range controller.GetController() {
+		if e, ok := ctrl.(controller.RateLimitExempter); ok && e.ExemptFromRateLimit(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isUIPath(path string) bool {
+	return path == webui.UIPath || strings.HasPrefix(path, webui.UIPath+"/")
+}
+
 func (as *ApiServer) Start(address string) {
 	as.init()
 
@@ -73,6 +136,31 @@ func (as *ApiServer) Close() {
 }
 
 func httpErrHandlerfunc(c *fiber.Ctx, err error) error {
+	if config.Cfg.LegacyErrors {
+		return legacyErrHandlerfunc(c, err)
+	}
+
+	p := controller.NewProblem(err, c.Path())
+	if p.Status >= fiber.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+	}
+
+	if p.Status == fiber.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, controller.MIMEProblemJSON)
+
+	return c.Status(p.Status).Send(body)
+}
+
+// error envelope of older versions, always with HTTP 200
+func legacyErrHandlerfunc(c *fiber.Ctx, err error) error {
 	var em *controller.CommonResp
 
 	// Retrieve the custom status code if it's a *fiber.Error
@@ -0,0 +1,23 @@
+package restapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/restapi/controller"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestProblemHidesInternalCause(t *testing.T) {
+	p := controller.NewProblem(errors.New("sql: no such table: certs"), "/v1/cas/user/certificates")
+	if p.Status != fiber.StatusInternalServerError || p.Detail != errs.Title(errs.CodeInternal) {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+
+	p = controller.NewProblem(controller.ErrBadRequest.Wrap(errors.New("bad json")), "/v1/cas/user/certificates")
+	if p.Status != fiber.StatusBadRequest || !strings.Contains(p.Detail, "bad json") {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+}
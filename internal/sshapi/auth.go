@@ -0,0 +1,190 @@
+package sshapi
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"golang.org/x/crypto/ssh"
+)
+
+// keys of ssh.Permissions extensions carrying the identity of the caller
+const (
+	// token id, or one derived from the key or user logged in with
+	extId     = "id"
+	extName   = "name"
+	extScopes = "scopes"
+	extMethod = "method"
+	// key the caller logged in with in wire format, empty for keyboard-interactive
+	extKey = "key"
+)
+
+// login methods
+const (
+	methodAuthorizedKey = "authorized-key"
+	methodCert          = "certificate"
+	methodToken         = "token"
+)
+
+var errUnknownKey = errors.New("unknown key")
+var errCertLoginDisabled = errors.New("certificate login is disabled")
+var errNotPrincipal = errors.New("user is not a principal of the certificate")
+
+type authenticator struct {
+	auth   *auth.Authenticator
+	userCA *service.SSHCertCAService
+	// read on each login, so edits take effect without restart
+	authorizedKeysPath string
+	certScopes         string
+}
+
+func (au *authenticator) publicKey(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	var perms *ssh.Permissions
+	var err error
+
+	if cert, ok := key.(*ssh.Certificate); ok {
+		perms, err = au.certificate(conn, cert)
+	} else {
+		perms, err = au.authorizedKey(key)
+	}
+	if err != nil {
+		log.Printf("ssh auth fail from %s: %s: %v", remoteIP(conn.RemoteAddr()), conn.User(), err)
+		return nil, err
+	}
+
+	return perms, nil
+}
+
+func (au *authenticator) authorizedKey(key ssh.PublicKey) (*ssh.Permissions, error) {
+	if au.authorizedKeysPath == "" {
+		return nil, errUnknownKey
+	}
+
+	rest, err := os.ReadFile(au.authorizedKeysPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(rest) > 0 {
+		var k ssh.PublicKey
+		var comment string
+		var options []string
+
+		k, comment, options, rest, err = ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			break
+		}
+
+		if !bytes.Equal(k.Marshal(), key.Marshal()) {
+			continue
+		}
+
+		name := comment
+		if name == "" {
+			name = ssh.FingerprintSHA256(k)
+		}
+
+		return newPermissions("key:"+ssh.FingerprintSHA256(k), name, scopesOption(options), methodAuthorizedKey, key), nil
+	}
+
+	return nil, errUnknownKey
+}
+
+// user certificates of this CA, the login user must be one of its principals
+func (au *authenticator) certificate(conn ssh.ConnMetadata, cert *ssh.Certificate) (*ssh.Permissions, error) {
+	if au.certScopes == "" {
+		return nil, errCertLoginDisabled
+	}
+
+	err := au.userCA.CheckCert(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range cert.ValidPrincipals {
+		if p == conn.User() {
+			return newPermissions("cert:"+conn.User(), conn.User(), au.certScopes, methodCert, cert.Key), nil
+		}
+	}
+
+	return nil, errNotPrincipal
+}
+
+// API token as the answer
+func (au *authenticator) keyboardInteractive(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	answers, err := client("", "", []string{"API token: "}, []bool{false})
+	if err != nil {
+		return nil, err
+	}
+	if len(answers) != 1 {
+		return nil, auth.ErrInvalidAuthKey
+	}
+
+	t, err := au.auth.Authenticate(answers[0])
+	if err != nil {
+		log.Printf("ssh auth fail from %s: %s: %v", remoteIP(conn.RemoteAddr()), conn.User(), err)
+		return nil, err
+	}
+
+	return newPermissions(t.Id, t.Name, t.Scopes, methodToken, nil), nil
+}
+
+func newPermissions(id, name, scopes, method string, key ssh.PublicKey) *ssh.Permissions {
+	perms := &ssh.Permissions{Extensions: map[string]string{
+		extId:     id,
+		extName:   name,
+		extScopes: scopes,
+		extMethod: method,
+	}}
+	if key != nil {
+		perms.Extensions[extKey] = string(key.Marshal())
+	}
+
+	return perms
+}
+
+// scopes="sign,revoke" option of an authorized key
+func scopesOption(options []string) string {
+	for _, o := range options {
+		if strings.HasPrefix(o, "scopes=") {
+			return strings.Trim(strings.TrimPrefix(o, "scopes="), "\"")
+		}
+	}
+
+	return model.DefaultScope
+}
+
+// caller of a session
+type caller struct {
+	model.Token
+	Method string
+	// nil for keyboard-interactive
+	Key ssh.PublicKey
+}
+
+func callerOf(perms *ssh.Permissions) *caller {
+	c := &caller{
+		Token: model.Token{
+			Id:     perms.Extensions[extId],
+			Name:   perms.Extensions[extName],
+			Scopes: perms.Extensions[extScopes],
+		},
+		Method: perms.Extensions[extMethod],
+	}
+
+	if k, ok := perms.Extensions[extKey]; ok {
+		c.Key, _ = ssh.ParsePublicKey([]byte(k))
+	}
+
+	return c
+}
+
+// the caller proved possession of the key by logging in with it
+func (c *caller) owns(key ssh.PublicKey) bool {
+	return c.Key != nil && bytes.Equal(c.Key.Marshal(), key.Marshal())
+}
@@ -0,0 +1,272 @@
+package sshapi
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/auth"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/service"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+)
+
+// lifetime of certificates requested without ttl, same as the REST API
+const defaultTTL = 24 * 365 * time.Hour
+
+var errInvalidArgs = errs.New(errs.CodeInvalidInput, "invalid arguments")
+var errNotKeyOwner = errs.New(errs.CodeInvalidSignature, "log in with the key to be signed to prove possession of it")
+var errNotRequester = errs.New(errs.CodeInsufficientScope, "request is made by someone else")
+var errRequestClosed = errs.New(errs.CodeRequestNotPending, "request is denied or expired")
+
+// request is held back for approval, it's not reported as error
+var errPending = errors.New("pending approval")
+
+type cmdContext struct {
+	app    *app.App
+	caller *caller
+	// login user
+	user   string
+	ip     string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands = map[string]func(ctx *cmdContext, args []string) error{
+	"sign":    sign,
+	"renew":   renew,
+	"krl":     krl,
+	"pubkey":  pubkey,
+	"whoami":  whoami,
+	"request": request,
+}
+
+func newFlagSet(ctx *cmdContext, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ctx.stderr)
+
+	return fs
+}
+
+func (ctx *cmdContext) requireScope(scope string) error {
+	if !ctx.caller.HasScope(scope) {
+		return auth.ErrInsufficientScope
+	}
+
+	return nil
+}
+
+func (ctx *cmdContext) caByRole(role string) (*service.SSHCertCAService, error) {
+	ct, err := model.ParseCertType(role)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.app.CAByRole(ct)
+}
+
+// sign the public key from stdin for the login user unless principals are given
+func sign(ctx *cmdContext, args []string) error {
+	fs := newFlagSet(ctx, "sign")
+	role := fs.String("role", "user", "CA role, user or host")
+	principals := fs.String("principals", ctx.user, "comma separated list of principals")
+	ttl := fs.Duration("ttl", defaultTTL, "certificate lifetime")
+	err := fs.Parse(args)
+	if err != nil || *principals == "" || *ttl <= 0 {
+		return errInvalidArgs
+	}
+
+	err = ctx.requireScope(model.ScopeSign)
+	if err != nil {
+		return err
+	}
+
+	signer, err := ctx.caByRole(*role)
+	if err != nil {
+		return err
+	}
+
+	in, err := io.ReadAll(ctx.stdin)
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := utils.ParseSSHPublicKey(in)
+	if err != nil {
+		return err
+	}
+
+	// proof of possession
+	if requireSignedNonce(signer.Role()) && !ctx.caller.owns(pubkey) {
+		return errNotKeyOwner
+	}
+
+	sreq := &service.SignRequest{
+		PublicKey:   pubkey,
+		Requester:   ctx.caller.Name,
+		RequesterId: ctx.caller.Id,
+		ClientIP:    ctx.ip,
+		Principals:  strings.Split(*principals, ","),
+		TTL:         *ttl,
+	}
+	// principal verified by the certificate logged in with
+	if ctx.caller.Method == methodCert {
+		sreq.Identity = ctx.user
+	}
+
+	cert, err := signer.Issue(sreq)
+	if errors.Is(err, policy.ErrApprovalRequired) {
+		cr, err := ctx.app.Approvals.Submit(signer.Role(), sreq)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(ctx.stderr, "request %s is pending %d approvals, collect the certificate with `request %s` once approved\n",
+			cr.Id, cr.Required, cr.Id)
+
+		return errPending
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(ctx.stdout, cert.Content)
+	return err
+}
+
+// renew the certificate from stdin, logging in with its key proves possession
+func renew(ctx *cmdContext, args []string) error {
+	fs := newFlagSet(ctx, "renew")
+	role := fs.String("role", "user", "CA role, user or host")
+	err := fs.Parse(args)
+	if err != nil {
+		return errInvalidArgs
+	}
+
+	signer, err := ctx.caByRole(*role)
+	if err != nil {
+		return err
+	}
+
+	in, err := io.ReadAll(ctx.stdin)
+	if err != nil {
+		return err
+	}
+
+	cert, err := utils.ParseSSHCert(in)
+	if err != nil {
+		return err
+	}
+
+	if !ctx.caller.owns(cert.Key) {
+		return errNotKeyOwner
+	}
+
+	renewed, err := signer.Renew(cert)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(ctx.stdout, renewed.Content)
+	return err
+}
+
+// present KRL in binary, ready to be used as RevokedKeys
+func krl(ctx *cmdContext, args []string) error {
+	fs := newFlagSet(ctx, "krl")
+	role := fs.String("role", "user", "CA role, user or host")
+	err := fs.Parse(args)
+	if err != nil {
+		return errInvalidArgs
+	}
+
+	err = ctx.requireScope(model.ScopeRead)
+	if err != nil {
+		return err
+	}
+
+	signer, err := ctx.caByRole(*role)
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.stdout.Write(signer.GetPresentRevokedList())
+	return err
+}
+
+func pubkey(ctx *cmdContext, args []string) error {
+	fs := newFlagSet(ctx, "pubkey")
+	role := fs.String("role", "user", "CA role, user or host")
+	err := fs.Parse(args)
+	if err != nil {
+		return errInvalidArgs
+	}
+
+	err = ctx.requireScope(model.ScopeRead)
+	if err != nil {
+		return err
+	}
+
+	signer, err := ctx.caByRole(*role)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(ctx.stdout, signer.PublicKeyAsAuthKeyStr())
+	return err
+}
+
+// certificate of the request held back for approval, only to the one made it
+func request(ctx *cmdContext, args []string) error {
+	if len(args) != 1 {
+		return errInvalidArgs
+	}
+
+	err := ctx.requireScope(model.ScopeSign)
+	if err != nil {
+		return err
+	}
+
+	cr, cert, err := ctx.app.Approvals.Get(args[0], ctx.caller.Id)
+	if err != nil {
+		return err
+	}
+
+	if cr.RequesterId != ctx.caller.Id {
+		return errNotRequester
+	}
+
+	if cert == nil {
+		fmt.Fprintf(ctx.stderr, "request %s is %s, %d/%d approvals\n", cr.Id, cr.Status, len(cr.Approvers), cr.Required)
+		if cr.Status == model.RequestPending {
+			return errPending
+		}
+
+		return errRequestClosed
+	}
+
+	_, err = fmt.Fprintln(ctx.stdout, cert.Content)
+	return err
+}
+
+func whoami(ctx *cmdContext, args []string) error {
+	_, err := fmt.Fprintf(ctx.stdout, "id: %s\nname: %s\nuser: %s\nscopes: %s\nmethod: %s\n",
+		ctx.caller.Id, ctx.caller.Name, ctx.user, ctx.caller.Scopes, ctx.caller.Method)
+	return err
+}
+
+func requireSignedNonce(role model.RoleType) bool {
+	if role == model.CertTypeHost {
+		return config.Cfg.HostCA.RequireSignedNonce
+	}
+
+	return config.Cfg.UserCA.RequireSignedNonce
+}
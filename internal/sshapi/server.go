@@ -0,0 +1,145 @@
+package sshapi
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/app"
+	"github.com/0w0mewo/ssh_cert_ca/internal/config"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+	"golang.org/x/crypto/ssh"
+)
+
+// SshServer takes certificate requests as ssh commands, e.g. ssh ca.example.com sign < id_ed25519.pub
+type SshServer struct {
+	app    *app.App
+	config *ssh.ServerConfig
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	lock     *sync.Mutex
+}
+
+func NewSshServer(cfg *config.SSHServerConfig) (*SshServer, error) {
+	hostKey, err := ca.LoadCAKeyPairs(cfg.HostKeyPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	a := app.Get()
+	ss := &SshServer{
+		app:   a,
+		conns: make(map[net.Conn]struct{}),
+		lock:  &sync.Mutex{},
+	}
+
+	au := &authenticator{
+		auth:               a.Auth,
+		userCA:             a.UserCA,
+		authorizedKeysPath: cfg.AuthorizedKeysPath,
+		certScopes:         cfg.CertScopes,
+	}
+
+	ss.config = &ssh.ServerConfig{
+		PublicKeyCallback: au.publicKey,
+		ServerVersion:     "SSH-2.0-ssh_cert_ca",
+	}
+	if cfg.KeyboardInteractive {
+		ss.config.KeyboardInteractiveCallback = au.keyboardInteractive
+	}
+	ss.config.AddHostKey(hostKey.Signer())
+
+	return ss, nil
+}
+
+func (ss *SshServer) Start(address string) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	ss.lock.Lock()
+	ss.listener = lis
+	ss.lock.Unlock()
+
+	log.Println("ssh server start at ", address)
+
+	for {
+		conn, err := lis.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		go ss.serveConn(conn)
+	}
+}
+
+func (ss *SshServer) serveConn(conn net.Conn) {
+	ss.lock.Lock()
+	ss.conns[conn] = struct{}{}
+	ss.lock.Unlock()
+
+	defer func() {
+		ss.lock.Lock()
+		delete(ss.conns, conn)
+		ss.lock.Unlock()
+
+		conn.Close()
+	}()
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, ss.config)
+	if err != nil {
+		log.Printf("ssh handshake fail from %s: %v", remoteIP(conn.RemoteAddr()), err)
+		return
+	}
+	defer sconn.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		go ss.serveSession(sconn, ch, requests)
+	}
+}
+
+func (ss *SshServer) Close() {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	if ss.listener != nil {
+		ss.listener.Close()
+	}
+
+	for conn := range ss.conns {
+		conn.Close()
+	}
+
+	log.Println("ssh server stop")
+}
+
+// address without port
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
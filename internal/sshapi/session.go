@@ -0,0 +1,108 @@
+package sshapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"golang.org/x/crypto/ssh"
+)
+
+const usage = `usage: ssh <ca server> <command> [flags]
+commands:
+  sign [-role user|host] [-principals a,b] [-ttl 8h] < key.pub   sign the public key from stdin
+  renew [-role user|host] < key-cert.pub                          renew the certificate from stdin
+  krl [-role user|host] > revoked_keys                            present KRL
+  pubkey [-role user|host]                                        CA public key
+  whoami                                                          identity and scopes of the caller
+`
+
+// exit statuses
+const (
+	exitOK = iota
+	exitError
+	// request is held back for approval
+	exitPending
+)
+
+// limit of public keys and certificates read from stdin
+const maxInput = 64 * 1024
+
+// a session runs a single command given by exec request, shell requests get the usage
+func (ss *SshServer) serveSession(sconn *ssh.ServerConn, ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			err := ssh.Unmarshal(req.Payload, &payload)
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+
+			req.Reply(true, nil)
+			ss.exit(ch, ss.exec(sconn, ch, payload.Command))
+
+			return
+		case "shell":
+			req.Reply(true, nil)
+			io.WriteString(ch.Stderr(), usage)
+			ss.exit(ch, exitError)
+
+			return
+		default:
+			// e.g. pty-req, env
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (ss *SshServer) exit(ch ssh.Channel, status int) {
+	ch.SendRequest("exit-status", false, ssh.Marshal(&struct{ Status uint32 }{uint32(status)}))
+}
+
+func (ss *SshServer) exec(sconn *ssh.ServerConn, ch ssh.Channel, command string) int {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		io.WriteString(ch.Stderr(), usage)
+		return exitError
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(ch.Stderr(), "unknown command %q\n%s", args[0], usage)
+		return exitError
+	}
+
+	ctx := &cmdContext{
+		app:    ss.app,
+		caller: callerOf(sconn.Permissions),
+		user:   sconn.User(),
+		ip:     remoteIP(sconn.RemoteAddr()),
+		stdin:  io.LimitReader(ch, maxInput),
+		stdout: ch,
+		stderr: ch.Stderr(),
+	}
+
+	log.Printf("ssh command from %s: %s by %s", ctx.ip, args[0], ctx.caller.Name)
+
+	err := cmd(ctx, args[1:])
+	if errors.Is(err, errPending) {
+		return exitPending
+	}
+	if err != nil {
+		if errs.KindOf(err) == errs.Internal {
+			log.Printf("ssh %s: %v", args[0], err)
+		}
+
+		fmt.Fprintf(ch.Stderr(), "error: %v (%s)\n", err, errs.CodeOf(err))
+		return exitError
+	}
+
+	return exitOK
+}
@@ -20,6 +20,21 @@ import (
 
 type SignerFunc func(pubkeyToSign ssh.PublicKey, keyid string, serial uint64, hostnames []string, ttl time.Duration) (c model.Cert, err error)
 
+// extensions of certificates signed without explicit extensions
+var DefaultExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// CertOptions are the permissions of certificate, nil Extensions means DefaultExtensions
+type CertOptions struct {
+	Extensions      map[string]string `json:"extensions,omitempty"`
+	CriticalOptions map[string]string `json:"critical_options,omitempty"`
+}
+
 type CAKeyPairs struct {
 	pubkey  ssh.PublicKey
 	privkey ssh.Signer
@@ -87,12 +102,21 @@ func generateKeyECPair(privateKeyFile, passparse string) error {
 	})
 }
 
+func (ckp *CAKeyPairs) PublicKey() ssh.PublicKey {
+	return ckp.pubkey
+}
+
+// private key, e.g. as host key of an ssh server
+func (ckp *CAKeyPairs) Signer() ssh.Signer {
+	return ckp.privkey
+}
+
 func (ckp *CAKeyPairs) PublicKeyAsAuthKeyStr() string {
 	return string(bytes.Trim(ssh.MarshalAuthorizedKey(ckp.pubkey), "\n"))
 
 }
 
-func (ckp *CAKeyPairs) Sign(pubkeyToSign ssh.PublicKey, keyid string, serial uint64, validPrincipals []string, ttl time.Duration, isHost bool) (c model.Cert, err error) {
+func (ckp *CAKeyPairs) Sign(pubkeyToSign ssh.PublicKey, keyid string, serial uint64, validPrincipals []string, ttl time.Duration, isHost bool, opts *CertOptions) (c model.Cert, err error) {
 	nonce := make([]byte, 32)
 	_, err = rand.Read(nonce)
 	if err != nil {
@@ -101,15 +125,23 @@ func (ckp *CAKeyPairs) Sign(pubkeyToSign ssh.PublicKey, keyid string, serial uin
 
 	var certType uint32
 	principals := make([]string, 0)
+	principals = append(principals, validPrincipals...)
 
 	if isHost {
 		certType = ssh.HostCert
 		c.Type = model.CertTypeHost
-		principals = append(principals, validPrincipals...)
 	} else {
 		certType = ssh.UserCert
 		c.Type = model.CerTypeUser
-		principals = append(principals, validPrincipals[0])
+	}
+
+	extensions := DefaultExtensions
+	var criticalOptions map[string]string
+	if opts != nil {
+		if opts.Extensions != nil {
+			extensions = opts.Extensions
+		}
+		criticalOptions = opts.CriticalOptions
 	}
 
 	c.ValidStart = time.Now()
@@ -126,13 +158,8 @@ func (ckp *CAKeyPairs) Sign(pubkeyToSign ssh.PublicKey, keyid string, serial uin
 		ValidAfter:      uint64(c.ValidStart.Unix()),
 		ValidBefore:     uint64(c.ValidEnd.Unix()),
 		Permissions: ssh.Permissions{
-			Extensions: map[string]string{
-				"permit-X11-forwarding":   "",
-				"permit-agent-forwarding": "",
-				"permit-port-forwarding":  "",
-				"permit-pty":              "",
-				"permit-user-rc":          "",
-			},
+			CriticalOptions: criticalOptions,
+			Extensions:      extensions,
 		},
 	}
 
@@ -146,7 +173,9 @@ func (ckp *CAKeyPairs) Sign(pubkeyToSign ssh.PublicKey, keyid string, serial uin
 	return
 }
 
-func (ckp *CAKeyPairs) GenerateRevokedList(certIds ...string) ([]byte, error) {
+// generate signed KRL revoking given cert key ids along with extra sections, e.g. from KRLs of an external CA,
+// version should increase every time the list changed
+func (ckp *CAKeyPairs) GenerateRevokedList(version uint64, extra []krl.KRLSection, certIds ...string) ([]byte, error) {
 	reovkedCerts := &krl.KRLCertificateSection{
 		CA: ckp.pubkey,
 	}
@@ -159,9 +188,15 @@ func (ckp *CAKeyPairs) GenerateRevokedList(certIds ...string) ([]byte, error) {
 
 	reovkedCerts.Sections = append(reovkedCerts.Sections, &ids)
 	k := &krl.KRL{
-		Sections: []krl.KRLSection{reovkedCerts},
+		Version:  version,
+		Sections: append([]krl.KRLSection{reovkedCerts}, extra...),
 	}
 
 	return k.Marshal(rand.Reader, ckp.privkey)
 
 }
+
+// sign the KRL with the CA key, e.g. one made by ssh-keygen -k which leaves KRLs unsigned
+func (ckp *CAKeyPairs) SignKRL(k *krl.KRL) ([]byte, error) {
+	return k.Marshal(rand.Reader, ckp.privkey)
+}
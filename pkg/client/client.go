@@ -0,0 +1,465 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrEmptyServer = errors.New("empty server address")
+var ErrLoginExpired = errors.New("login expired")
+var ErrRequestDenied = errors.New("request denied")
+var ErrRequestExpired = errors.New("request expired before approved")
+
+// Client talks to the CA REST API
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+type commonResp struct {
+	Code   int             `json:"code"`
+	ErrMsg string          `json:"errMsg"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// error returned by the CA server, ErrCode is the code from the error catalogue of the server,
+// Code is the one of legacy error envelope
+type APIError struct {
+	Status  int
+	Code    int
+	ErrCode string
+	Msg     string
+}
+
+func (e *APIError) Error() string {
+	if e.ErrCode != "" {
+		return fmt.Sprintf("ca server error (http %d, %s): %s", e.Status, e.ErrCode, e.Msg)
+	}
+
+	return fmt.Sprintf("ca server error (http %d, code %d): %s", e.Status, e.Code, e.Msg)
+}
+
+// RFC 7807 problem details returned on errors
+type problem struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Code   string `json:"code"`
+}
+
+// returned by Sign if the request is held back for approval
+type PendingApprovalError struct {
+	Request *model.CertRequest
+}
+
+func (e *PendingApprovalError) Error() string {
+	return fmt.Sprintf("request %s is pending approval", e.Request.Id)
+}
+
+func New(server, token string) (*Client, error) {
+	if server == "" {
+		return nil, ErrEmptyServer
+	}
+
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+
+	return &Client{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *Client) newRequest(method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return req, nil
+}
+
+// send request and decode data of the common response to out
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeResp(resp, out)
+}
+
+func decodeResp(resp *http.Response, out any) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") {
+		var p problem
+		err = json.Unmarshal(body, &p)
+		if err != nil {
+			return &APIError{Status: resp.StatusCode, Code: -1, Msg: strings.TrimSpace(string(body))}
+		}
+
+		return &APIError{Status: resp.StatusCode, Code: -1, ErrCode: p.Code, Msg: p.Detail}
+	}
+
+	var cr commonResp
+	err = json.Unmarshal(body, &cr)
+	if err != nil && resp.StatusCode >= 400 {
+		return &APIError{Status: resp.StatusCode, Code: -1, Msg: strings.TrimSpace(string(body))}
+	}
+	if err != nil {
+		return fmt.Errorf("decode response (http %d): %w", resp.StatusCode, err)
+	}
+
+	if cr.Code != 0 || resp.StatusCode >= 400 {
+		return &APIError{Status: resp.StatusCode, Code: cr.Code, Msg: cr.ErrMsg}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(cr.Data, out)
+}
+
+type SignRequest struct {
+	Role       string
+	PublicKey  []byte // authorized_keys format
+	Principals []string
+	TTL        time.Duration
+	// prove possession of the key by signing a challenge nonce if it's set
+	Signer ssh.Signer
+}
+
+// get a proof of possession challenge for the authorized_keys formatted public key
+func (c *Client) Challenge(role string, pubkey []byte) (*verify.Challenge, error) {
+	req, err := c.newRequest(http.MethodPost, "/v1/cas/"+url.PathEscape(role)+"/challenges", nil, bytes.NewReader(pubkey))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	ch := &verify.Challenge{}
+	err = c.do(req, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// request a certificate
+func (c *Client) Sign(sr *SignRequest) (*model.Cert, error) {
+	q := url.Values{}
+	q.Set("signto", strings.Join(sr.Principals, ","))
+	if sr.TTL > 0 {
+		q.Set("ttl", strconv.FormatUint(uint64(sr.TTL/time.Second), 10))
+	}
+
+	if sr.Signer != nil {
+		ch, err := c.Challenge(sr.Role, sr.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		sig, err := verify.SignChallenge(sr.Signer, ch.Nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		q.Set("challenge", ch.Id)
+		q.Set("signature", sig)
+	}
+
+	req, err := c.newRequest(http.MethodPost, "/v1/cas/"+url.PathEscape(sr.Role)+"/certificates", q, bytes.NewReader(sr.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusAccepted {
+		pending := &model.CertRequest{}
+		err = decodeResp(resp, pending)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, &PendingApprovalError{Request: pending}
+	}
+
+	cert := &model.Cert{}
+	err = decodeResp(resp, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// wait for approvers to decide on the request and collect the certificate
+func (c *Client) WaitApproval(id string, interval time.Duration) (*model.Cert, error) {
+	for {
+		req, err := c.newRequest(http.MethodGet, "/v1/requests/"+url.PathEscape(id), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			model.CertRequest
+			Cert *model.Cert `json:"cert"`
+		}
+		err = c.do(req, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result.Status {
+		case model.RequestIssued:
+			return result.Cert, nil
+		case model.RequestDenied:
+			return nil, ErrRequestDenied
+		case model.RequestExpired:
+			return nil, ErrRequestExpired
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (c *Client) GetCAPublicKey(role string) (string, error) {
+	req, err := c.newRequest(http.MethodGet, "/v1/cas/"+url.PathEscape(role)+"/public-key", nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var pubkey string
+	err = c.do(req, &pubkey)
+
+	return pubkey, err
+}
+
+// renew a still valid certificate, authenticated by signing a challenge with the certificate's key
+// instead of bearer token
+func (c *Client) Renew(role string, cert []byte, signer ssh.Signer) (*model.Cert, error) {
+	req, err := c.newRequest(http.MethodPost, "/renew/challenge/"+url.PathEscape(role), nil, bytes.NewReader(cert))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	ch := &verify.Challenge{}
+	err = c.do(req, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := verify.SignChallenge(signer, ch.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("challenge", ch.Id)
+	q.Set("signature", sig)
+
+	req, err = c.newRequest(http.MethodPost, "/renew/"+url.PathEscape(role), q, bytes.NewReader(cert))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	renewed := &model.Cert{}
+	err = c.do(req, renewed)
+	if err != nil {
+		return nil, err
+	}
+
+	return renewed, nil
+}
+
+type OIDCLogin struct {
+	Id                      string    `json:"id"`
+	State                   string    `json:"state,omitempty"`
+	AuthURL                 string    `json:"auth_url,omitempty"`
+	UserCode                string    `json:"user_code,omitempty"`
+	VerificationURI         string    `json:"verification_uri,omitempty"`
+	VerificationURIComplete string    `json:"verification_uri_complete,omitempty"`
+	Interval                int       `json:"interval,omitempty"`
+	ExpiresAt               time.Time `json:"expires_at"`
+}
+
+// start OIDC authorization code flow for the authorized_keys formatted public key,
+// the provider redirects the browser to redirectURI on loopback address, see ReceiveAuthCode
+func (c *Client) StartOIDCLogin(pubkey []byte, redirectURI string) (*OIDCLogin, error) {
+	q := url.Values{}
+	q.Set("redirect_uri", redirectURI)
+
+	return c.startOIDC("/oidc/login", q, pubkey)
+}
+
+// start OIDC device code flow for the authorized_keys formatted public key, see WaitOIDCLogin
+func (c *Client) StartOIDCDevice(pubkey []byte) (*OIDCLogin, error) {
+	return c.startOIDC("/oidc/device", nil, pubkey)
+}
+
+func (c *Client) startOIDC(path string, query url.Values, pubkey []byte) (*OIDCLogin, error) {
+	req, err := c.newRequest(http.MethodPost, path, query, bytes.NewReader(pubkey))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	login := &OIDCLogin{}
+	err = c.do(req, login)
+	if err != nil {
+		return nil, err
+	}
+
+	return login, nil
+}
+
+// exchange the authorization code received on the redirect for the certificate
+func (c *Client) ExchangeOIDCCode(login *OIDCLogin, code string) (*model.Cert, error) {
+	body, err := json.Marshal(map[string]string{"id": login.Id, "state": login.State, "code": code})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(http.MethodPost, "/oidc/exchange", nil, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Cert *model.Cert `json:"cert"`
+	}
+	err = c.do(req, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Cert == nil {
+		return nil, ErrLoginExpired
+	}
+
+	return result.Cert, nil
+}
+
+// wait for the OIDC device login to finish and collect the certificate,
+// the server holds each poll until the login finishes or a while passes
+func (c *Client) WaitOIDCLogin(login *OIDCLogin) (*model.Cert, error) {
+	for time.Now().Before(login.ExpiresAt) {
+		req, err := c.newRequest(http.MethodGet, "/oidc/result/"+url.PathEscape(login.Id), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Status string      `json:"status"`
+			Cert   *model.Cert `json:"cert"`
+		}
+		err = c.do(req, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Cert != nil {
+			return result.Cert, nil
+		}
+	}
+
+	return nil, ErrLoginExpired
+}
+
+// get the KRL of given role, it's base64 encoded
+func (c *Client) GetRevoked(role string) (string, error) {
+	req, err := c.newRequest(http.MethodGet, "/v1/cas/"+url.PathEscape(role)+"/krl", nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var krl string
+	err = c.do(req, &krl)
+
+	return krl, err
+}
+
+// conditionally get the KRL of given role, changed is false if it's still the one of given etag
+func (c *Client) GetRevokedIfChanged(role, etag string) (krl []byte, newEtag string, changed bool, err error) {
+	req, err := c.newRequest(http.MethodGet, "/v1/cas/"+url.PathEscape(role)+"/krl", nil, nil)
+	if err != nil {
+		return
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, false, nil
+	}
+
+	var encoded string
+	err = decodeResp(resp, &encoded)
+	if err != nil {
+		return
+	}
+
+	krl, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return
+	}
+
+	return krl, resp.Header.Get("ETag"), true, nil
+}
+
+func (c *Client) Revoke(role, keyid string) error {
+	req, err := c.newRequest(http.MethodDelete, "/v1/cas/"+url.PathEscape(role)+"/certificates/"+url.PathEscape(keyid), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+var ErrStateMismatch = errors.New("state of redirect does not match the login")
+
+// path of the loopback redirect
+const callbackPath = "/callback"
+
+// ListenLoopback listens on a random port of 127.0.0.1 for the redirect of OIDC authorization code flow,
+// the returned uri is the redirect_uri to start the login with
+func ListenLoopback() (net.Listener, string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return l, "http://" + l.Addr().String() + callbackPath, nil
+}
+
+// ReceiveAuthCode serves the loopback redirect till the browser comes back with the authorization code
+// of the login, the listener is closed on return
+func ReceiveAuthCode(l net.Listener, login *OIDCLogin) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		// redirects of other logins are ignored, so a stray request does not end this one
+		if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(login.State)) != 1 {
+			http.Error(w, ErrStateMismatch.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res := result{code: q.Get("code")}
+		if e := q.Get("error"); e != "" {
+			res.err = fmt.Errorf("oidc: %s: %s", e, q.Get("error_description"))
+		} else if res.code == "" {
+			res.err = errors.New("oidc: no code in redirect")
+		}
+
+		if res.err != nil {
+			http.Error(w, "login failed: "+res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "login succeeded, you can close this page and return to your terminal.")
+		}
+
+		select {
+		case done <- res:
+		default:
+		}
+	})
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	select {
+	case res := <-done:
+		return res.code, res.err
+	case <-time.After(time.Until(login.ExpiresAt)):
+		return "", ErrLoginExpired
+	}
+}
@@ -0,0 +1,124 @@
+package errs
+
+// stable error codes of the API, never change or reuse them
+const (
+	CodeInternal          = "internal"
+	CodeInvalidInput      = "invalid_input"
+	CodeInvalidKey        = "invalid_key"
+	CodeInvalidCert       = "invalid_cert"
+	CodeUnsupportedRole   = "unsupported_role"
+	CodeUnsupportedMedia  = "unsupported_media_type"
+	CodeUnauthorized      = "unauthorized"
+	CodeInsufficientScope = "insufficient_scope"
+	CodeInvalidSignature  = "invalid_signature"
+	CodeChallengeNotFound = "challenge_not_found"
+	CodeHostKeyMismatch   = "host_key_mismatch"
+	CodePolicyDenied      = "policy_denied"
+	CodeUnknownIdentity   = "unknown_identity"
+	CodeNoPrincipals      = "no_principals"
+	CodeCertRevoked       = "cert_revoked"
+	CodeNotRenewable      = "not_renewable"
+	CodeNotFound          = "not_found"
+	CodeConflict          = "conflict"
+	CodeRequestNotPending = "request_not_pending"
+	CodeAlreadyApproved   = "already_approved"
+	CodeNotApprover       = "not_approver"
+	CodeCooldown          = "cooldown"
+	CodeRateLimited       = "rate_limited"
+	CodeUnavailable       = "unavailable"
+)
+
+// catalogue entry of an error code
+type Entry struct {
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
+
+var catalogue = []struct {
+	code  string
+	kind  Kind
+	title string
+}{
+	{CodeInternal, Internal, "Internal error"},
+	{CodeInvalidInput, Invalid, "Invalid input"},
+	{CodeInvalidKey, InvalidKey, "Invalid public key"},
+	{CodeInvalidCert, Unauthorized, "Invalid certificate"},
+	{CodeUnsupportedRole, Invalid, "Unsupported CA role"},
+	{CodeUnsupportedMedia, UnsupportedMedia, "Unsupported media type"},
+	{CodeUnauthorized, Unauthorized, "Unauthorized"},
+	{CodeInsufficientScope, Forbidden, "Insufficient token scope"},
+	{CodeInvalidSignature, Unauthorized, "Invalid proof of possession"},
+	{CodeChallengeNotFound, Unauthorized, "Challenge not found or expired"},
+	{CodeHostKeyMismatch, Forbidden, "Host key mismatch"},
+	{CodePolicyDenied, Forbidden, "Denied by policy"},
+	{CodeUnknownIdentity, Forbidden, "Unknown identity"},
+	{CodeNoPrincipals, Invalid, "No principals"},
+	{CodeCertRevoked, Forbidden, "Certificate revoked"},
+	{CodeNotRenewable, Forbidden, "Certificate not renewable"},
+	{CodeNotFound, NotFound, "Not found"},
+	{CodeConflict, Conflict, "Conflict"},
+	{CodeRequestNotPending, Conflict, "Request not pending"},
+	{CodeAlreadyApproved, Conflict, "Already approved"},
+	{CodeNotApprover, Forbidden, "Not an approver"},
+	{CodeCooldown, TooManyRequests, "Cooling down"},
+	{CodeRateLimited, TooManyRequests, "Too many requests"},
+	{CodeUnavailable, Unavailable, "Service unavailable"},
+}
+
+// every error code with its title and HTTP status
+func Catalogue() []Entry {
+	res := make([]Entry, 0, len(catalogue))
+	for _, e := range catalogue {
+		res = append(res, Entry{Code: e.code, Title: e.title, Status: e.kind.HTTPStatus()})
+	}
+
+	return res
+}
+
+func kindOfCode(code string) Kind {
+	for _, e := range catalogue {
+		if e.code == code {
+			return e.kind
+		}
+	}
+
+	panic("error code " + code + " is not in the catalogue")
+}
+
+// title of the error code, empty if it's not in the catalogue
+func Title(code string) string {
+	for _, e := range catalogue {
+		if e.code == code {
+			return e.title
+		}
+	}
+
+	return ""
+}
+
+// code of generic errors of the kind, for errors not typed by the application
+func GenericCode(kind Kind) string {
+	switch kind {
+	case Invalid:
+		return CodeInvalidInput
+	case InvalidKey:
+		return CodeInvalidKey
+	case Unauthorized:
+		return CodeUnauthorized
+	case Forbidden:
+		return CodePolicyDenied
+	case NotFound:
+		return CodeNotFound
+	case Conflict:
+		return CodeConflict
+	case TooManyRequests:
+		return CodeRateLimited
+	case UnsupportedMedia:
+		return CodeUnsupportedMedia
+	case Unavailable:
+		return CodeUnavailable
+	}
+
+	return CodeInternal
+}
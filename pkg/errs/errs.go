@@ -0,0 +1,126 @@
+package errs
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies errors across layers, it decides the HTTP status of API responses
+type Kind int
+
+const (
+	Internal Kind = iota
+	Invalid
+	InvalidKey
+	Unauthorized
+	Forbidden
+	NotFound
+	Conflict
+	TooManyRequests
+	UnsupportedMedia
+	Unavailable
+)
+
+// Error is an error with a kind and a stable code from the catalogue
+type Error struct {
+	Kind Kind
+	Code string
+	Msg  string
+	err  error
+	// sentinel the error is wrapped from
+	origin *Error
+}
+
+func (e *Error) Error() string {
+	if e.err != nil {
+		return e.Msg + ": " + e.err.Error()
+	}
+
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// errors.Is matches wrapped errors to their sentinel
+func (e *Error) Is(target error) bool {
+	return e.origin != nil && target == error(e.origin)
+}
+
+// define a sentinel error, kind comes from the catalogue entry of code
+func New(code, msg string) *Error {
+	return &Error{Kind: kindOfCode(code), Code: code, Msg: msg}
+}
+
+// attach the cause to a copy of the sentinel error
+func (e *Error) Wrap(err error) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Msg: e.Msg, err: err, origin: e}
+}
+
+// kind of the error, Internal if it's not typed
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return Internal
+}
+
+// code of the error, "internal" if it's not typed
+func CodeOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return CodeInternal
+}
+
+func (k Kind) HTTPStatus() int {
+	switch k {
+	case Invalid, InvalidKey:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case TooManyRequests:
+		return http.StatusTooManyRequests
+	case UnsupportedMedia:
+		return http.StatusUnsupportedMediaType
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// kind of errors carrying a HTTP status, e.g. from the web framework
+func KindOfHTTPStatus(status int) Kind {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusMethodNotAllowed:
+		return Invalid
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusTooManyRequests:
+		return TooManyRequests
+	case http.StatusUnsupportedMediaType:
+		return UnsupportedMedia
+	case http.StatusServiceUnavailable:
+		return Unavailable
+	}
+
+	return Internal
+}
@@ -0,0 +1,250 @@
+package event
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	PriorityNormal = "normal"
+	// e.g. emergency access, operators should be alerted
+	PriorityHigh = "high"
+)
+
+// event types
+const (
+	TypeBreakGlass = "breakglass"
+	// a new KRL is generated
+	TypeKRL = "krl"
+	// certificate lifecycle
+	TypeCertIssued  = "cert.issued"
+	TypeCertRevoked = "cert.revoked"
+)
+
+// number of recent events kept for subscribers resuming from an event id
+const historySize = 256
+
+type Event struct {
+	Id       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Priority string    `json:"priority"`
+	Time     time.Time `json:"time"`
+	Data     any       `json:"data"`
+}
+
+// Bus fans events out to subscribers, slow subscribers miss events instead of blocking publishers
+// unless they are queued ones
+type Bus struct {
+	subs    map[uint64]chan *Event
+	queues  map[uint64]*queue
+	nextId  uint64
+	lastId  uint64
+	history []*Event
+	lock    *sync.Mutex
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs:   make(map[uint64]chan *Event),
+		queues: make(map[uint64]*queue),
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (b *Bus) Publish(typ, priority string, data any) *Event {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.lastId++
+	e := &Event{
+		Id:       b.lastId,
+		Type:     typ,
+		Priority: priority,
+		Time:     time.Now(),
+		Data:     data,
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > 2*historySize {
+		b.history = append([]*Event(nil), b.history[len(b.history)-historySize:]...)
+	}
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+
+	for _, q := range b.queues {
+		q.push(e)
+	}
+
+	return e
+}
+
+// receive published events until cancel is called
+func (b *Bus) Subscribe(buffer int) (events <-chan *Event, cancel func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.subscribe(buffer)
+}
+
+// receive published events of given types until cancel is called, none of them is missed.
+// events wait in a queue while the subscriber is busy, publishers are never blocked
+func (b *Bus) SubscribeQueued(types ...string) (events <-chan *Event, cancel func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	id := b.nextId
+	b.nextId++
+
+	q := newQueue(types)
+	b.queues[id] = q
+
+	ch := make(chan *Event)
+	go q.run(ch)
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.lock.Lock()
+			delete(b.queues, id)
+			b.lock.Unlock()
+
+			close(q.done)
+		})
+	}
+
+	return ch, cancel
+}
+
+// subscribe along with the events published after event id, complete is false if some of
+// them are no longer in history, or the id is unknown e.g. it's from before a restart
+func (b *Bus) SubscribeSince(after uint64, buffer int) (missed []*Event, complete bool, events <-chan *Event, cancel func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	missed, complete = b.since(after)
+	events, cancel = b.subscribe(buffer)
+
+	return
+}
+
+func (b *Bus) since(after uint64) ([]*Event, bool) {
+	if after > b.lastId {
+		return nil, false
+	}
+
+	i := sort.Search(len(b.history), func(i int) bool {
+		return b.history[i].Id > after
+	})
+
+	// the event right after is gone
+	if i == 0 && after < b.lastId && (len(b.history) == 0 || b.history[0].Id > after+1) {
+		return nil, false
+	}
+
+	return append([]*Event(nil), b.history[i:]...), true
+}
+
+func (b *Bus) subscribe(buffer int) (events <-chan *Event, cancel func()) {
+	id := b.nextId
+	b.nextId++
+
+	ch := make(chan *Event, buffer)
+	b.subs[id] = ch
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.lock.Lock()
+			defer b.lock.Unlock()
+
+			delete(b.subs, id)
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
+
+// unbounded queue of events of a subscriber
+type queue struct {
+	types  map[string]bool
+	events []*Event
+	lock   *sync.Mutex
+	// signalled when an event is pushed
+	notify chan struct{}
+	done   chan struct{}
+}
+
+func newQueue(types []string) *queue {
+	q := &queue{
+		types:  make(map[string]bool),
+		lock:   &sync.Mutex{},
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	for _, t := range types {
+		q.types[t] = true
+	}
+
+	return q
+}
+
+func (q *queue) push(e *Event) {
+	if !q.types[e.Type] {
+		return
+	}
+
+	q.lock.Lock()
+	q.events = append(q.events, e)
+	q.lock.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) pop() *Event {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.events) == 0 {
+		return nil
+	}
+
+	e := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+
+	return e
+}
+
+// hand queued events to out in order until the queue is done
+func (q *queue) run(out chan<- *Event) {
+	defer close(out)
+
+	for {
+		e := q.pop()
+		if e == nil {
+			select {
+			case <-q.notify:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case out <- e:
+		case <-q.done:
+			return
+		}
+	}
+}
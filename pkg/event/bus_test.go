@@ -0,0 +1,53 @@
+package event
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeQueued(t *testing.T) {
+	b := NewBus()
+
+	events, cancel := b.SubscribeQueued(TypeBreakGlass)
+	defer cancel()
+
+	// far more than a buffered subscriber holds, published while nobody receives
+	const n = 1000
+	for i := 0; i < n; i++ {
+		b.Publish(TypeKRL, PriorityNormal, i)
+		b.Publish(TypeBreakGlass, PriorityHigh, i)
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-events:
+			if e.Type != TypeBreakGlass || e.Data != i {
+				t.Fatalf("expected break-glass event %d, got %s %v", i, e.Type, e.Data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d is missed", i)
+		}
+	}
+
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %s %v", e.Type, e.Data)
+	default:
+	}
+}
+
+func TestSubscribeQueuedCancel(t *testing.T) {
+	b := NewBus()
+
+	events, cancel := b.SubscribeQueued(TypeBreakGlass)
+	b.Publish(TypeBreakGlass, PriorityHigh, nil)
+	cancel()
+	cancel()
+
+	// the channel is closed on cancel
+	for range events {
+	}
+
+	// publishing to a cancelled subscriber does not block
+	b.Publish(TypeBreakGlass, PriorityHigh, nil)
+}
@@ -0,0 +1,185 @@
+package identity
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/go-ldap/ldap/v3"
+)
+
+var ErrAmbiguousIdentity = errs.New(errs.CodeUnknownIdentity, "identity matches more than one directory entry")
+
+// Directory is the subset of LDAP operations used by the resolver, *ldap.Conn satisfies it.
+// tests may dial an in-process stand-in instead of a real server
+type Directory interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// principals and extensions granted to members of a directory group, critical options forced on them
+type GroupMapping struct {
+	Principals      []string          `json:"principals"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+	CriticalOptions map[string]string `json:"critical_options,omitempty"`
+}
+
+type LDAPResolver struct {
+	URL                string
+	BindDN             string
+	BindPassword       string
+	StartTLS           bool
+	InsecureSkipVerify bool
+
+	// %s is replaced with the escaped identity, e.g. (uid=%s)
+	UserBaseDN string
+	UserFilter string
+
+	// %s is replaced with the escaped user DN, e.g. (member=%s)
+	GroupBaseDN string
+	GroupFilter string
+	// attribute holding the group name matched against Groups, e.g. cn
+	GroupAttr string
+
+	Groups map[string]*GroupMapping
+
+	// connect to the directory, defaults to dialing URL
+	Dial func() (Directory, error)
+}
+
+func (r *LDAPResolver) dial() (Directory, error) {
+	if r.Dial != nil {
+		return r.Dial()
+	}
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: r.InsecureSkipVerify}
+	if u, err := url.Parse(r.URL); err == nil {
+		tlsCfg.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(r.URL, ldap.DialWithTLSConfig(tlsCfg))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StartTLS {
+		err = conn.StartTLS(tlsCfg)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// look up groups of the identity and collect principals and extensions mapped from them
+func (r *LDAPResolver) Resolve(identity string) (*Resolution, error) {
+	dir, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	if r.BindDN != "" {
+		err = dir.Bind(r.BindDN, r.BindPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	userDN, err := r.findUser(dir, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := r.findGroups(dir, userDN)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.mapGroups(groups), nil
+}
+
+func (r *LDAPResolver) findUser(dir Directory, identity string) (string, error) {
+	res, err := dir.Search(ldap.NewSearchRequest(r.UserBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(r.UserFilter, ldap.EscapeFilter(identity)),
+		[]string{"dn"}, nil))
+	if err != nil {
+		return "", err
+	}
+
+	switch len(res.Entries) {
+	case 0:
+		return "", ErrUnknownIdentity
+	case 1:
+		return res.Entries[0].DN, nil
+	}
+
+	return "", ErrAmbiguousIdentity
+}
+
+func (r *LDAPResolver) findGroups(dir Directory, userDN string) ([]string, error) {
+	res, err := dir.Search(ldap.NewSearchRequest(r.GroupBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(r.GroupFilter, ldap.EscapeFilter(userDN)),
+		[]string{r.GroupAttr}, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		groups = append(groups, e.GetAttributeValues(r.GroupAttr)...)
+	}
+
+	return groups, nil
+}
+
+// union of principals and extensions of every mapped group, unmapped groups are ignored.
+// a critical option forced by several groups takes the value of the first one
+func (r *LDAPResolver) mapGroups(groups []string) *Resolution {
+	res := &Resolution{}
+	seen := make(map[string]bool)
+
+	for _, g := range groups {
+		m, ok := r.Groups[g]
+		if !ok {
+			m, ok = r.Groups[strings.ToLower(g)]
+		}
+		if !ok {
+			continue
+		}
+
+		for _, p := range m.Principals {
+			if !seen[p] {
+				seen[p] = true
+				res.Principals = append(res.Principals, p)
+			}
+		}
+
+		if m.Extensions != nil {
+			if res.Extensions == nil {
+				res.Extensions = make(map[string]string)
+			}
+			for k, v := range m.Extensions {
+				res.Extensions[k] = v
+			}
+		}
+
+		for k, v := range m.CriticalOptions {
+			if res.CriticalOptions == nil {
+				res.CriticalOptions = make(map[string]string)
+			}
+			if _, exist := res.CriticalOptions[k]; !exist {
+				res.CriticalOptions[k] = v
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,343 @@
+package identity
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testBindDN       = "cn=ssh-ca,ou=services,dc=example,dc=com"
+	testBindPassword = "secret"
+)
+
+type dirEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// in-process LDAP stand-in answering simple binds and searches of equality, presence, and, or filters
+type standInDirectory struct {
+	entries []*dirEntry
+
+	lock     sync.Mutex
+	searches int
+}
+
+func startDirectory(t *testing.T, entries ...*dirEntry) (*standInDirectory, string) {
+	t.Helper()
+
+	d := &standInDirectory{entries: entries}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go d.serve(conn)
+		}
+	}()
+
+	return d, "ldap://" + l.Addr().String()
+}
+
+func (d *standInDirectory) searchCount() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.searches
+}
+
+func (d *standInDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+
+	bound := false
+
+	for {
+		msg, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(msg.Children) < 2 {
+			return
+		}
+
+		id := msg.Children[0].Value.(int64)
+		op := msg.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			name, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+			bound = name == testBindDN && password == testBindPassword
+
+			code := ldap.LDAPResultSuccess
+			if !bound {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			d.reply(conn, id, result(ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			if !bound {
+				d.reply(conn, id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				continue
+			}
+
+			d.lock.Lock()
+			d.searches++
+			d.lock.Unlock()
+
+			base, filter := op.Children[0].Data.String(), op.Children[6]
+			var attrs []string
+			for _, a := range op.Children[7].Children {
+				attrs = append(attrs, a.Data.String())
+			}
+
+			for _, e := range d.entries {
+				if inScope(e.dn, base) && matchFilter(filter, e) {
+					d.reply(conn, id, searchEntry(e, attrs))
+				}
+			}
+			d.reply(conn, id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationUnbindRequest:
+			return
+
+		default:
+			return
+		}
+	}
+}
+
+func (d *standInDirectory) reply(w io.Writer, id int64, op *ber.Packet) {
+	msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	msg.AppendChild(op)
+
+	w.Write(msg.Bytes())
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+
+	return p
+}
+
+func searchEntry(e *dirEntry, attrs []string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, ""))
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for _, name := range attrs {
+		vals, ok := e.attrs[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range vals {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	p.AppendChild(list)
+
+	return p
+}
+
+func inScope(dn, base string) bool {
+	dn, base = strings.ToLower(dn), strings.ToLower(base)
+	return dn == base || strings.HasSuffix(dn, ","+base)
+}
+
+func matchFilter(f *ber.Packet, e *dirEntry) bool {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			if !matchFilter(c, e) {
+				return false
+			}
+		}
+		return true
+
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			if matchFilter(c, e) {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterEqualityMatch:
+		attr, value := strings.ToLower(f.Children[0].Data.String()), f.Children[1].Data.String()
+		for _, v := range e.attrs[attr] {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterPresent:
+		_, ok := e.attrs[strings.ToLower(f.Data.String())]
+		return ok
+	}
+
+	return false
+}
+
+func person(uid, mail string) *dirEntry {
+	return &dirEntry{
+		dn:    "uid=" + uid + ",ou=people,dc=example,dc=com",
+		attrs: map[string][]string{"uid": {uid}, "mail": {mail}, "objectclass": {"person"}},
+	}
+}
+
+func group(cn string, members ...*dirEntry) *dirEntry {
+	e := &dirEntry{
+		dn:    "cn=" + cn + ",ou=groups,dc=example,dc=com",
+		attrs: map[string][]string{"cn": {cn}, "objectclass": {"groupOfNames"}},
+	}
+	for _, m := range members {
+		e.attrs["member"] = append(e.attrs["member"], m.dn)
+	}
+
+	return e
+}
+
+func newTestResolver(url string, groups map[string]*GroupMapping) *LDAPResolver {
+	return &LDAPResolver{
+		URL:          url,
+		BindDN:       testBindDN,
+		BindPassword: testBindPassword,
+		UserBaseDN:   "ou=people,dc=example,dc=com",
+		UserFilter:   "(mail=%s)",
+		GroupBaseDN:  "ou=groups,dc=example,dc=com",
+		GroupFilter:  "(member=%s)",
+		GroupAttr:    "cn",
+		Groups:       groups,
+	}
+}
+
+func TestLDAPResolve(t *testing.T) {
+	alice, bob := person("alice", "alice@example.com"), person("bob", "bob@example.com")
+	_, url := startDirectory(t, alice, bob,
+		group("Ops", alice),
+		group("devs", alice, bob),
+		group("contractors", alice),
+		group("printers", alice))
+
+	r := newTestResolver(url, map[string]*GroupMapping{
+		"ops":         {Principals: []string{"root", "ops"}, CriticalOptions: map[string]string{"source-address": "10.0.0.0/8"}},
+		"devs":        {Principals: []string{"dev", "ops"}, Extensions: map[string]string{"permit-pty": ""}},
+		"contractors": {Principals: []string{"dev"}, CriticalOptions: map[string]string{"source-address": "0.0.0.0/0"}},
+	})
+
+	res, err := r.Resolve("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(res.Principals, ",") != "root,ops,dev" {
+		t.Fatalf("unexpected principals %v", res.Principals)
+	}
+	if _, ok := res.Extensions["permit-pty"]; !ok || len(res.Extensions) != 1 {
+		t.Fatalf("unexpected extensions %v", res.Extensions)
+	}
+	if res.CriticalOptions["source-address"] != "10.0.0.0/8" {
+		t.Fatalf("expected critical option of the first group, got %v", res.CriticalOptions)
+	}
+
+	res, err = r.Resolve("bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(res.Principals, ",") != "dev,ops" || res.CriticalOptions != nil {
+		t.Fatalf("unexpected resolution of bob %+v", res)
+	}
+}
+
+func TestLDAPResolveUnknownIdentity(t *testing.T) {
+	_, url := startDirectory(t, person("alice", "alice@example.com"))
+	r := newTestResolver(url, nil)
+
+	for _, id := range []string{"carol@example.com", "*", "alice@example.com)(mail=*"} {
+		_, err := r.Resolve(id)
+		if !errors.Is(err, ErrUnknownIdentity) {
+			t.Fatalf("expected ErrUnknownIdentity for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestLDAPResolveAmbiguousIdentity(t *testing.T) {
+	_, url := startDirectory(t, person("alice", "shared@example.com"), person("bob", "shared@example.com"))
+	r := newTestResolver(url, nil)
+
+	_, err := r.Resolve("shared@example.com")
+	if !errors.Is(err, ErrAmbiguousIdentity) {
+		t.Fatalf("expected ErrAmbiguousIdentity, got %v", err)
+	}
+}
+
+func TestLDAPResolveBindFailure(t *testing.T) {
+	_, url := startDirectory(t, person("alice", "alice@example.com"))
+	r := newTestResolver(url, nil)
+	r.BindPassword = "wrong"
+
+	_, err := r.Resolve("alice@example.com")
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestCachedResolver(t *testing.T) {
+	alice := person("alice", "alice@example.com")
+	d, url := startDirectory(t, alice, group("devs", alice))
+
+	r := NewCachedResolver(newTestResolver(url, map[string]*GroupMapping{"devs": {Principals: []string{"dev"}}}), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		res, err := r.Resolve("alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Principals) != 1 || res.Principals[0] != "dev" {
+			t.Fatalf("unexpected principals %v", res.Principals)
+		}
+	}
+
+	// user and group search once
+	if n := d.searchCount(); n != 2 {
+		t.Fatalf("expected directory to be searched once, got %d searches", n)
+	}
+
+	// failures are not cached
+	for i := 0; i < 2; i++ {
+		_, err := r.Resolve("carol@example.com")
+		if !errors.Is(err, ErrUnknownIdentity) {
+			t.Fatalf("expected ErrUnknownIdentity, got %v", err)
+		}
+	}
+	if n := d.searchCount(); n != 4 {
+		t.Fatalf("expected failed lookups to reach the directory, got %d searches", n)
+	}
+}
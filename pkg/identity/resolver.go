@@ -0,0 +1,79 @@
+package identity
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+)
+
+var ErrUnknownIdentity = errs.New(errs.CodeUnknownIdentity, "unknown identity")
+
+// principals and certificate extensions granted to an identity
+type Resolution struct {
+	Principals []string
+	// nil means default extensions
+	Extensions map[string]string
+	// forced whatever is requested, e.g. source-address
+	CriticalOptions map[string]string
+}
+
+// Resolver maps requester identity, e.g. user name or email, to what it is allowed to be signed for
+type Resolver interface {
+	Resolve(identity string) (*Resolution, error)
+}
+
+type cacheEntry struct {
+	res       *Resolution
+	expiresAt time.Time
+}
+
+// CachedResolver remembers results of the underlying resolver for a while
+type CachedResolver struct {
+	backend Resolver
+	ttl     time.Duration
+	entries map[string]*cacheEntry
+	lock    *sync.Mutex
+}
+
+func NewCachedResolver(backend Resolver, ttl time.Duration) *CachedResolver {
+	return &CachedResolver{
+		backend: backend,
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (r *CachedResolver) Resolve(identity string) (*Resolution, error) {
+	r.lock.Lock()
+	e, ok := r.entries[identity]
+	r.lock.Unlock()
+
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.res, nil
+	}
+
+	// failures are not cached, directory outage should not outlive itself
+	res, err := r.backend.Resolve(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.purgeExpired()
+	r.entries[identity] = &cacheEntry{res: res, expiresAt: time.Now().Add(r.ttl)}
+
+	return res, nil
+}
+
+func (r *CachedResolver) purgeExpired() {
+	now := time.Now()
+	for id, e := range r.entries {
+		if now.After(e.expiresAt) {
+			delete(r.entries, id)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerifiedEmail is the email claim if the provider verified it and its domain is one of domains,
+// compared case insensitively. anyone may set an unverified email, e.g. root@example.com
+func VerifiedEmail(claims map[string]any, domains []string) (string, bool) {
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified || email == "" {
+		return "", false
+	}
+
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return "", false
+	}
+
+	for _, d := range domains {
+		if strings.EqualFold(domain, d) {
+			return email, true
+		}
+	}
+
+	return "", false
+}
+
+// PrincipalsFromClaims maps ID token claims to principals by claim specs.
+// a spec is either "<claim>" or "<claim>:localpart", e.g. "email:localpart", "preferred_username" or "groups".
+// string and string array claims are supported, principals are deduplicated in order.
+// the email claim is only used if it's verified and of one of emailDomains
+func PrincipalsFromClaims(claims map[string]any, specs []string, emailDomains []string) []string {
+	res := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(v string, localpart bool) {
+		if localpart {
+			v, _, _ = strings.Cut(v, "@")
+		}
+
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			return
+		}
+
+		seen[v] = true
+		res = append(res, v)
+	}
+
+	for _, spec := range specs {
+		name, modifier, _ := strings.Cut(spec, ":")
+		localpart := modifier == "localpart"
+
+		if name == "email" {
+			if email, ok := VerifiedEmail(claims, emailDomains); ok {
+				add(email, localpart)
+			}
+			continue
+		}
+
+		switch v := claims[name].(type) {
+		case string:
+			add(v, localpart)
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					add(s, localpart)
+				}
+			}
+		case nil:
+		default:
+			add(fmt.Sprint(v), localpart)
+		}
+	}
+
+	return res
+}
+
+// identity the user is known by, email of allowed domains if it's verified otherwise subject
+func (id *Identity) Name(emailDomains []string) string {
+	if email, ok := VerifiedEmail(id.Claims, emailDomains); ok {
+		return email
+	}
+
+	return id.Subject
+}
@@ -0,0 +1,233 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrNoIDToken = errors.New("no id_token in token response")
+var ErrDeviceFlowUnsupported = errors.New("provider does not support device authorization")
+var ErrAuthorizationPending = errors.New("authorization pending")
+var ErrSlowDown = errors.New("slow down")
+
+// Identity is the verified claims of an ID token
+type Identity struct {
+	Subject string
+	Claims  map[string]any
+}
+
+type DeviceAuth struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// Provider runs authorization code (with PKCE) and device code flows against an OIDC provider
+type Provider struct {
+	oauth2        oauth2.Config
+	verifier      *gooidc.IDTokenVerifier
+	deviceAuthURL string
+	http          *http.Client
+}
+
+// redirect uri is given on each login, see IsLoopbackRedirect
+func NewProvider(ctx context.Context, issuer, clientID, clientSecret string, scopes []string) (*Provider, error) {
+	p, err := gooidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery struct {
+		DeviceAuthURL string `json:"device_authorization_endpoint"`
+	}
+	err = p.Claims(&discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+	}
+
+	return &Provider{
+		oauth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     p.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier:      p.Verifier(&gooidc.Config{ClientID: clientID}),
+		deviceAuthURL: discovery.DeviceAuthURL,
+		http:          &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// random PKCE code verifier
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// IsLoopbackRedirect tells if uri is a loopback redirect of a native app, e.g. http://127.0.0.1:<port>/callback (RFC 8252).
+// authorization codes sent there only reach the machine of the user logging in
+func IsLoopbackRedirect(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" || u.User != nil || u.Fragment != "" {
+		return false
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// url to redirect user to for authorization code flow
+func (p *Provider) AuthCodeURL(state, codeVerifier, redirectURI string) string {
+	return p.oauth2.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("redirect_uri", redirectURI),
+		oauth2.SetAuthURLParam("code_challenge", codeChallengeS256(codeVerifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+}
+
+// exchange authorization code sent to redirectURI and verify the returned ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, redirectURI string) (*Identity, error) {
+	tok, err := p.oauth2.Exchange(ctx, code,
+		oauth2.SetAuthURLParam("redirect_uri", redirectURI),
+		oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrNoIDToken
+	}
+
+	return p.verify(ctx, rawIDToken)
+}
+
+func (p *Provider) verify(ctx context.Context, rawIDToken string) (*Identity, error) {
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]any)
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Subject: idToken.Subject, Claims: claims}, nil
+}
+
+// start device authorization for headless clients
+func (p *Provider) StartDeviceAuth(ctx context.Context) (*DeviceAuth, error) {
+	if p.deviceAuthURL == "" {
+		return nil, ErrDeviceFlowUnsupported
+	}
+
+	form := url.Values{}
+	form.Set("client_id", p.oauth2.ClientID)
+	form.Set("scope", strings.Join(p.oauth2.Scopes, " "))
+
+	da := &DeviceAuth{}
+	err := p.postForm(ctx, p.deviceAuthURL, form, da)
+	if err != nil {
+		return nil, err
+	}
+
+	if da.Interval <= 0 {
+		da.Interval = 5
+	}
+
+	return da, nil
+}
+
+// poll token endpoint once for the device code,
+// ErrAuthorizationPending or ErrSlowDown is returned if user has not finished yet
+func (p *Provider) PollDeviceToken(ctx context.Context, deviceCode string) (*Identity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	form.Set("device_code", deviceCode)
+	form.Set("client_id", p.oauth2.ClientID)
+	if p.oauth2.ClientSecret != "" {
+		form.Set("client_secret", p.oauth2.ClientSecret)
+	}
+
+	var tok struct {
+		IDToken string `json:"id_token"`
+	}
+	err := p.postForm(ctx, p.oauth2.Endpoint.TokenURL, form, &tok)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	return p.verify(ctx, tok.IDToken)
+}
+
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (p *Provider) postForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var oe oauthError
+		json.NewDecoder(resp.Body).Decode(&oe)
+
+		switch oe.Error {
+		case "authorization_pending":
+			return ErrAuthorizationPending
+		case "slow_down":
+			return ErrSlowDown
+		}
+
+		return fmt.Errorf("oidc: %s (http %d): %s", oe.Error, resp.StatusCode, oe.Description)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
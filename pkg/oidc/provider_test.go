@@ -0,0 +1,496 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "ssh-cert-ca"
+	testClientSecret = "secret"
+)
+
+type mockAuthCode struct {
+	challenge   string
+	redirectURI string
+}
+
+type mockDevice struct {
+	userCode string
+	approved bool
+}
+
+// local mock OIDC provider, issuing RS256 signed ID tokens of claims to any login it's asked for
+type mockProvider struct {
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+
+	lock    sync.Mutex
+	codes   map[string]*mockAuthCode
+	devices map[string]*mockDevice
+	// device polls answered with slow_down before anything else
+	slowDowns int
+}
+
+func newMockProvider(t *testing.T, claims map[string]any) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{
+		key:     key,
+		claims:  claims,
+		codes:   make(map[string]*mockAuthCode),
+		devices: make(map[string]*mockDevice),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/device", m.device)
+
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+
+	return m
+}
+
+func (m *mockProvider) issuer() string {
+	return m.srv.URL
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func oauthErr(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.issuer(),
+		"authorization_endpoint":                m.issuer() + "/authorize",
+		"token_endpoint":                        m.issuer() + "/token",
+		"jwks_uri":                              m.issuer() + "/jwks",
+		"device_authorization_endpoint":         m.issuer() + "/device",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+// logs the user in at once and redirects back with a code
+func (m *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	m.lock.Lock()
+	m.codes[code] = &mockAuthCode{challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri")}
+	m.lock.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockProvider) device(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.PostForm.Get("client_id") != testClientID {
+		oauthErr(w, "invalid_client")
+		return
+	}
+
+	deviceCode, userCode := randomString(), "ABCD-EFGH"
+
+	m.lock.Lock()
+	m.devices[deviceCode] = &mockDevice{userCode: userCode}
+	m.lock.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":      deviceCode,
+		"user_code":        userCode,
+		"verification_uri": m.issuer() + "/activate",
+		"expires_in":       600,
+		"interval":         1,
+	})
+}
+
+// user enters the code and logs in
+func (m *mockProvider) approveDevice(deviceCode string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.devices[deviceCode].approved = true
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	form := r.PostForm
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = form.Get("client_id"), form.Get("client_secret")
+	}
+	if clientID != testClientID || secret != testClientSecret {
+		oauthErr(w, "invalid_client")
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	switch form.Get("grant_type") {
+	case "authorization_code":
+		code, exist := m.codes[form.Get("code")]
+		delete(m.codes, form.Get("code"))
+		if !exist || code.redirectURI != form.Get("redirect_uri") {
+			oauthErr(w, "invalid_grant")
+			return
+		}
+
+		sum := sha256.Sum256([]byte(form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			oauthErr(w, "invalid_grant")
+			return
+		}
+
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		d, exist := m.devices[form.Get("device_code")]
+		switch {
+		case !exist:
+			oauthErr(w, "expired_token")
+			return
+		case m.slowDowns > 0:
+			m.slowDowns--
+			oauthErr(w, "slow_down")
+			return
+		case !d.approved:
+			oauthErr(w, "authorization_pending")
+			return
+		}
+		delete(m.devices, form.Get("device_code"))
+
+	default:
+		oauthErr(w, "unsupported_grant_type")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     m.idToken(),
+	})
+}
+
+func (m *mockProvider) idToken() string {
+	claims := map[string]any{
+		"iss": m.issuer(),
+		"aud": testClientID,
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, sum[:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestProvider(t *testing.T, m *mockProvider) *Provider {
+	t.Helper()
+
+	p, err := NewProvider(context.Background(), m.issuer(), testClientID, testClientSecret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// follow the authorization url as a browser would, up to the redirect back to the client
+func authorizeInBrowser(t *testing.T, authURL string) url.Values {
+	t.Helper()
+
+	browser := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect from provider, got http %d", resp.StatusCode)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc.Query()
+}
+
+var testEmailDomains = []string{"example.com"}
+
+func TestAuthCodeFlow(t *testing.T) {
+	m := newMockProvider(t, map[string]any{"email": "alice@example.com", "email_verified": true, "groups": []string{"dev", "ops"}})
+	p := newTestProvider(t, m)
+
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := "http://127.0.0.1:8123/callback"
+
+	authURL := p.AuthCodeURL("state-1", verifier, redirect)
+	if !strings.HasPrefix(authURL, m.issuer()+"/authorize?") {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	back := authorizeInBrowser(t, authURL)
+	if back.Get("state") != "state-1" {
+		t.Fatalf("expected state to be passed back, got %q", back.Get("state"))
+	}
+
+	id, err := p.Exchange(context.Background(), back.Get("code"), verifier, redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Subject != "user-1" || id.Name(testEmailDomains) != "alice@example.com" {
+		t.Fatalf("unexpected identity %s (%s)", id.Name(testEmailDomains), id.Subject)
+	}
+
+	principals := PrincipalsFromClaims(id.Claims, []string{"email:localpart", "groups"}, testEmailDomains)
+	if strings.Join(principals, ",") != "alice,dev,ops" {
+		t.Fatalf("unexpected principals %v", principals)
+	}
+}
+
+func TestAuthCodeFlowWrongVerifier(t *testing.T) {
+	m := newMockProvider(t, nil)
+	p := newTestProvider(t, m)
+
+	verifier, _ := NewCodeVerifier()
+	other, _ := NewCodeVerifier()
+	redirect := "http://127.0.0.1:8123/callback"
+
+	back := authorizeInBrowser(t, p.AuthCodeURL("s", verifier, redirect))
+
+	_, err := p.Exchange(context.Background(), back.Get("code"), other, redirect)
+	if err == nil {
+		t.Fatal("expected code exchange with another verifier to fail")
+	}
+}
+
+func TestAuthCodeFlowRedirectMismatch(t *testing.T) {
+	m := newMockProvider(t, nil)
+	p := newTestProvider(t, m)
+
+	verifier, _ := NewCodeVerifier()
+	back := authorizeInBrowser(t, p.AuthCodeURL("s", verifier, "http://127.0.0.1:8123/callback"))
+
+	_, err := p.Exchange(context.Background(), back.Get("code"), verifier, "http://127.0.0.1:9000/callback")
+	if err == nil {
+		t.Fatal("expected code exchange for another redirect uri to fail")
+	}
+}
+
+func TestCodeUsedOnce(t *testing.T) {
+	m := newMockProvider(t, nil)
+	p := newTestProvider(t, m)
+
+	verifier, _ := NewCodeVerifier()
+	redirect := "http://127.0.0.1:8123/callback"
+	back := authorizeInBrowser(t, p.AuthCodeURL("s", verifier, redirect))
+
+	_, err := p.Exchange(context.Background(), back.Get("code"), verifier, redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Exchange(context.Background(), back.Get("code"), verifier, redirect)
+	if err == nil {
+		t.Fatal("expected second exchange of the same code to fail")
+	}
+}
+
+func TestIDTokenOfAnotherProvider(t *testing.T) {
+	m := newMockProvider(t, nil)
+	p := newTestProvider(t, m)
+
+	// same issuer url and key id, another signing key
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := m.key
+	m.key = forged
+	token := m.idToken()
+	m.key = real
+
+	_, err = p.verify(context.Background(), token)
+	if err == nil {
+		t.Fatal("expected ID token signed by another key to be refused")
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	m := newMockProvider(t, map[string]any{"preferred_username": "bob"})
+	m.slowDowns = 1
+	p := newTestProvider(t, m)
+
+	da, err := p.StartDeviceAuth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if da.UserCode == "" || da.Interval != 1 {
+		t.Fatalf("unexpected device auth %+v", da)
+	}
+
+	_, err = p.PollDeviceToken(context.Background(), da.DeviceCode)
+	if !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("expected ErrSlowDown, got %v", err)
+	}
+
+	_, err = p.PollDeviceToken(context.Background(), da.DeviceCode)
+	if !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("expected ErrAuthorizationPending, got %v", err)
+	}
+
+	m.approveDevice(da.DeviceCode)
+
+	id, err := p.PollDeviceToken(context.Background(), da.DeviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principals := PrincipalsFromClaims(id.Claims, []string{"preferred_username"}, nil)
+	if len(principals) != 1 || principals[0] != "bob" {
+		t.Fatalf("unexpected principals %v", principals)
+	}
+}
+
+func TestIsLoopbackRedirect(t *testing.T) {
+	for uri, want := range map[string]bool{
+		"http://127.0.0.1:8123/callback":      true,
+		"http://[::1]:8123/callback":          true,
+		"http://127.0.0.1/callback":           true,
+		"https://127.0.0.1:8123/callback":     false,
+		"http://localhost:8123/callback":      false,
+		"http://ca.example.com/oidc/callback": false,
+		"http://10.0.0.1:8123/callback":       false,
+		"http://127.0.0.1.evil.com/callback":  false,
+		"http://user@127.0.0.1:8123/callback": false,
+		"http://127.0.0.1:8123/callback#frag": false,
+		"javascript://127.0.0.1/%0aalert(1)":  false,
+		"":                                    false,
+	} {
+		if got := IsLoopbackRedirect(uri); got != want {
+			t.Errorf("IsLoopbackRedirect(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
+
+func TestPrincipalsFromUntrustedEmail(t *testing.T) {
+	specs := []string{"email:localpart", "groups"}
+
+	for _, tc := range []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"unverified", map[string]any{"sub": "user-2", "email": "root@example.com", "groups": []any{"dev"}}},
+		{"verified false", map[string]any{"sub": "user-2", "email": "root@example.com", "email_verified": false, "groups": []any{"dev"}}},
+		{"verified as string", map[string]any{"sub": "user-2", "email": "root@example.com", "email_verified": "true", "groups": []any{"dev"}}},
+		{"other domain", map[string]any{"sub": "user-2", "email": "root@evil.example.org", "email_verified": true, "groups": []any{"dev"}}},
+		{"subdomain", map[string]any{"sub": "user-2", "email": "root@sub.example.com", "email_verified": true, "groups": []any{"dev"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			principals := PrincipalsFromClaims(tc.claims, specs, testEmailDomains)
+			if strings.Join(principals, ",") != "dev" {
+				t.Fatalf("unexpected principals %v", principals)
+			}
+
+			id := &Identity{Subject: "user-2", Claims: tc.claims}
+			if id.Name(testEmailDomains) != "user-2" {
+				t.Fatalf("expected subject as identity, got %s", id.Name(testEmailDomains))
+			}
+		})
+	}
+
+	// no domain is trusted unless configured
+	claims := map[string]any{"email": "alice@example.com", "email_verified": true}
+	if principals := PrincipalsFromClaims(claims, specs, nil); len(principals) != 0 {
+		t.Fatalf("unexpected principals %v", principals)
+	}
+
+	// domains are compared case insensitively
+	claims["email"] = "alice@EXAMPLE.com"
+	if principals := PrincipalsFromClaims(claims, specs, testEmailDomains); strings.Join(principals, ",") != "alice" {
+		t.Fatalf("unexpected principals %v", principals)
+	}
+}
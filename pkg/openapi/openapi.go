@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// subset of OpenAPI 3 document needed to describe and validate the API
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components *Components           `json:"components,omitempty"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// operations by lower case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	OneOf       []*Schema          `json:"oneOf,omitempty"`
+	// schema of values of a map, or false to forbid unknown properties
+	AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+}
+
+// an operation of the document
+type Route struct {
+	Method string // upper case
+	Path   string // with {param} placeholders
+}
+
+func Parse(b []byte) (*Document, error) {
+	var d Document
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// every operation of the document, sorted by path and method
+func (d *Document) Routes() []Route {
+	res := make([]Route, 0)
+	for path, item := range d.Paths {
+		for method := range item {
+			res = append(res, Route{Method: strings.ToUpper(method), Path: path})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path != res[j].Path {
+			return res[i].Path < res[j].Path
+		}
+
+		return res[i].Method < res[j].Method
+	})
+
+	return res
+}
+
+// find operation of request method and path, along with values of path parameters.
+// literal segments win over parameters, HEAD is served by GET operation
+func (d *Document) Find(method, path string) (*Operation, map[string]string) {
+	method = strings.ToLower(method)
+	if method == "head" {
+		method = "get"
+	}
+
+	var found *Operation
+	var foundParams map[string]string
+
+	segs := splitPath(path)
+	for tmpl, item := range d.Paths {
+		op := item[method]
+		if op == nil {
+			continue
+		}
+
+		params, ok := matchPath(splitPath(tmpl), segs)
+		if ok && (found == nil || len(params) < len(foundParams)) {
+			found, foundParams = op, params
+		}
+	}
+
+	return found, foundParams
+}
+
+// whether the operation needs credentials, by its own security requirements or the document's.
+// an empty list marks the operation public
+func (d *Document) Secured(op *Operation) bool {
+	if op.Security != nil {
+		return len(op.Security) > 0
+	}
+
+	return len(d.Security) > 0
+}
+
+// schema the reference points to, only local component schemas are supported
+func (d *Document) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		if d.Components == nil {
+			return nil
+		}
+
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+
+	return s
+}
+
+// path segments, trailing slash is ignored as the router does
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func matchPath(tmpl, segs []string) (map[string]string, bool) {
+	if len(tmpl) != len(segs) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, t := range tmpl {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segs[i] == "" {
+				return nil, false
+			}
+
+			params[t[1:len(t)-1]] = segs[i]
+			continue
+		}
+
+		if !strings.EqualFold(t, segs[i]) {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
@@ -0,0 +1,312 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+)
+
+var ErrInvalidRequest = errs.New(errs.CodeInvalidInput, "request does not match API specification")
+var ErrUnsupportedMedia = errs.New(errs.CodeUnsupportedMedia, "unsupported media type")
+
+// media type assumed for bodies without content type
+const defaultMediaType = "application/octet-stream"
+
+// request to be validated against the document
+type Request struct {
+	Method      string
+	Path        string
+	Query       url.Values
+	ContentType string
+	Body        []byte
+}
+
+// check parameters and body of request against its operation, requests of
+// operations unknown to the document are left to the router
+func (d *Document) Validate(req *Request) error {
+	op, params := d.Find(req.Method, req.Path)
+	if op == nil {
+		return nil
+	}
+
+	for _, p := range op.Parameters {
+		var v string
+		switch p.In {
+		case "path":
+			v = params[p.Name]
+		case "query":
+			v = req.Query.Get(p.Name)
+		default:
+			continue
+		}
+
+		if v == "" {
+			if p.Required {
+				return invalid("%s parameter %q is required", p.In, p.Name)
+			}
+
+			continue
+		}
+
+		err := d.validateString(p.Schema, v)
+		if err != nil {
+			return invalid("%s parameter %q %v", p.In, p.Name, err)
+		}
+	}
+
+	if op.RequestBody != nil {
+		return d.validateBody(op.RequestBody, req)
+	}
+
+	return nil
+}
+
+func (d *Document) validateBody(rb *RequestBody, req *Request) error {
+	if len(req.Body) == 0 {
+		if rb.Required {
+			return invalid("request body is required")
+		}
+
+		return nil
+	}
+
+	mediaType := defaultMediaType
+	if req.ContentType != "" {
+		mt, _, err := mime.ParseMediaType(req.ContentType)
+		if err != nil {
+			return ErrUnsupportedMedia.Wrap(err)
+		}
+
+		mediaType = mt
+	}
+
+	content, ok := rb.Content[mediaType]
+	if !ok {
+		supported := make([]string, 0, len(rb.Content))
+		for mt := range rb.Content {
+			supported = append(supported, mt)
+		}
+		sort.Strings(supported)
+
+		return ErrUnsupportedMedia.Wrap(fmt.Errorf("%q, expect one of %s", mediaType, strings.Join(supported, ", ")))
+	}
+
+	// only JSON bodies are described by schema
+	if content.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(req.Body))
+	dec.UseNumber()
+
+	err := dec.Decode(&v)
+	if err != nil {
+		return invalid("body is not valid JSON: %v", err)
+	}
+
+	return d.validateValue(content.Schema, v, "body")
+}
+
+// parameter values are strings, converted by type of schema
+func (d *Document) validateString(s *Schema, v string) error {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+
+		return checkMinimum(s, float64(n))
+
+	case "number":
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+
+		return checkMinimum(s, n)
+
+	case "boolean":
+		_, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+
+		return nil
+	}
+
+	return checkString(s, v)
+}
+
+func (d *Document) validateValue(s *Schema, v any, at string) error {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			if d.validateValue(alt, v, at) == nil {
+				return nil
+			}
+		}
+
+		return invalid("%s matches none of the allowed schemas", at)
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return typeMismatch(at, s.Type)
+		}
+
+		return d.validateObject(s, m, at)
+
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return typeMismatch(at, s.Type)
+		}
+
+		for i, item := range arr {
+			err := d.validateValue(s.Items, item, fmt.Sprintf("%s[%d]", at, i))
+			if err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return typeMismatch(at, s.Type)
+		}
+
+		err := checkString(s, str)
+		if err != nil {
+			return invalid("%s %v", at, err)
+		}
+
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return typeMismatch(at, s.Type)
+		}
+
+		f, err := n.Float64()
+		if err != nil || (s.Type == "integer" && strings.ContainsAny(n.String(), ".eE")) {
+			return typeMismatch(at, s.Type)
+		}
+
+		err = checkMinimum(s, f)
+		if err != nil {
+			return invalid("%s %v", at, err)
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeMismatch(at, s.Type)
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, m map[string]any, at string) error {
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			return invalid("%s.%s is required", at, name)
+		}
+	}
+
+	var additional *Schema
+	closed := false
+	if len(s.AdditionalProperties) > 0 {
+		if string(s.AdditionalProperties) == "false" {
+			closed = true
+		} else {
+			additional = &Schema{}
+			err := json.Unmarshal(s.AdditionalProperties, additional)
+			if err != nil {
+				additional = nil
+			}
+		}
+	}
+
+	for name, pv := range m {
+		ps, ok := s.Properties[name]
+		if !ok {
+			if closed {
+				return invalid("%s.%s is not allowed", at, name)
+			}
+
+			ps = additional
+		}
+
+		err := d.validateValue(ps, pv, at+"."+name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkString(s *Schema, v string) error {
+	if s.MinLength != nil && len(v) < *s.MinLength {
+		return fmt.Errorf("must be at least %d characters", *s.MinLength)
+	}
+
+	if s.Pattern != "" {
+		ok, err := regexp.MatchString(s.Pattern, v)
+		if err != nil || !ok {
+			return fmt.Errorf("must match %s", s.Pattern)
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == v {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("must be one of %v", s.Enum)
+	}
+
+	return nil
+}
+
+func checkMinimum(s *Schema, v float64) error {
+	if s.Minimum != nil && v < *s.Minimum {
+		return fmt.Errorf("must be at least %v", *s.Minimum)
+	}
+
+	return nil
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func invalid(format string, args ...any) error {
+	return ErrInvalidRequest.Wrap(fmt.Errorf(format, args...))
+}
+
+func typeMismatch(at, typ string) error {
+	return invalid("%s must be of type %s", at, typ)
+}
@@ -0,0 +1,325 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: ca.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CertificateAuthorityClient is the client API for CertificateAuthority service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CertificateAuthorityClient interface {
+	// issue a nonce to be signed by the key to be signed, sign scope
+	CreateChallenge(ctx context.Context, in *CreateChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	// sign a public key, requests needing approval are held back, sign scope
+	Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error)
+	// revoke a certificate by key id, revoke scope
+	Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*RevokeResponse, error)
+	// CA public key, read scope
+	GetPublicKey(ctx context.Context, in *GetPublicKeyRequest, opts ...grpc.CallOption) (*GetPublicKeyResponse, error)
+	// issued certificates, read scope
+	ListCerts(ctx context.Context, in *ListCertsRequest, opts ...grpc.CallOption) (*ListCertsResponse, error)
+	// the present KRL, then every new one once it changes, read scope
+	WatchKRL(ctx context.Context, in *WatchKRLRequest, opts ...grpc.CallOption) (CertificateAuthority_WatchKRLClient, error)
+}
+
+type certificateAuthorityClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCertificateAuthorityClient(cc grpc.ClientConnInterface) CertificateAuthorityClient {
+	return &certificateAuthorityClient{cc}
+}
+
+func (c *certificateAuthorityClient) CreateChallenge(ctx context.Context, in *CreateChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, "/sshca.v1.CertificateAuthority/CreateChallenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	out := new(SignResponse)
+	err := c.cc.Invoke(ctx, "/sshca.v1.CertificateAuthority/Sign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*RevokeResponse, error) {
+	out := new(RevokeResponse)
+	err := c.cc.Invoke(ctx, "/sshca.v1.CertificateAuthority/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) GetPublicKey(ctx context.Context, in *GetPublicKeyRequest, opts ...grpc.CallOption) (*GetPublicKeyResponse, error) {
+	out := new(GetPublicKeyResponse)
+	err := c.cc.Invoke(ctx, "/sshca.v1.CertificateAuthority/GetPublicKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) ListCerts(ctx context.Context, in *ListCertsRequest, opts ...grpc.CallOption) (*ListCertsResponse, error) {
+	out := new(ListCertsResponse)
+	err := c.cc.Invoke(ctx, "/sshca.v1.CertificateAuthority/ListCerts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certificateAuthorityClient) WatchKRL(ctx context.Context, in *WatchKRLRequest, opts ...grpc.CallOption) (CertificateAuthority_WatchKRLClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CertificateAuthority_ServiceDesc.Streams[0], "/sshca.v1.CertificateAuthority/WatchKRL", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &certificateAuthorityWatchKRLClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CertificateAuthority_WatchKRLClient interface {
+	Recv() (*KRL, error)
+	grpc.ClientStream
+}
+
+type certificateAuthorityWatchKRLClient struct {
+	grpc.ClientStream
+}
+
+func (x *certificateAuthorityWatchKRLClient) Recv() (*KRL, error) {
+	m := new(KRL)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CertificateAuthorityServer is the server API for CertificateAuthority service.
+// All implementations must embed UnimplementedCertificateAuthorityServer
+// for forward compatibility
+type CertificateAuthorityServer interface {
+	// issue a nonce to be signed by the key to be signed, sign scope
+	CreateChallenge(context.Context, *CreateChallengeRequest) (*Challenge, error)
+	// sign a public key, requests needing approval are held back, sign scope
+	Sign(context.Context, *SignRequest) (*SignResponse, error)
+	// revoke a certificate by key id, revoke scope
+	Revoke(context.Context, *RevokeRequest) (*RevokeResponse, error)
+	// CA public key, read scope
+	GetPublicKey(context.Context, *GetPublicKeyRequest) (*GetPublicKeyResponse, error)
+	// issued certificates, read scope
+	ListCerts(context.Context, *ListCertsRequest) (*ListCertsResponse, error)
+	// the present KRL, then every new one once it changes, read scope
+	WatchKRL(*WatchKRLRequest, CertificateAuthority_WatchKRLServer) error
+	mustEmbedUnimplementedCertificateAuthorityServer()
+}
+
+// UnimplementedCertificateAuthorityServer must be embedded to have forward compatible implementations.
+type UnimplementedCertificateAuthorityServer struct {
+}
+
+func (UnimplementedCertificateAuthorityServer) CreateChallenge(context.Context, *CreateChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateChallenge not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) Sign(context.Context, *SignRequest) (*SignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sign not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) Revoke(context.Context, *RevokeRequest) (*RevokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) GetPublicKey(context.Context, *GetPublicKeyRequest) (*GetPublicKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPublicKey not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) ListCerts(context.Context, *ListCertsRequest) (*ListCertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCerts not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) WatchKRL(*WatchKRLRequest, CertificateAuthority_WatchKRLServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchKRL not implemented")
+}
+func (UnimplementedCertificateAuthorityServer) mustEmbedUnimplementedCertificateAuthorityServer() {}
+
+// UnsafeCertificateAuthorityServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CertificateAuthorityServer will
+// result in compilation errors.
+type UnsafeCertificateAuthorityServer interface {
+	mustEmbedUnimplementedCertificateAuthorityServer()
+}
+
+func RegisterCertificateAuthorityServer(s grpc.ServiceRegistrar, srv CertificateAuthorityServer) {
+	s.RegisterService(&CertificateAuthority_ServiceDesc, srv)
+}
+
+func _CertificateAuthority_CreateChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).CreateChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sshca.v1.CertificateAuthority/CreateChallenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).CreateChallenge(ctx, req.(*CreateChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sshca.v1.CertificateAuthority/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).Sign(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sshca.v1.CertificateAuthority/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).Revoke(ctx, req.(*RevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_GetPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).GetPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sshca.v1.CertificateAuthority/GetPublicKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).GetPublicKey(ctx, req.(*GetPublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_ListCerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateAuthorityServer).ListCerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sshca.v1.CertificateAuthority/ListCerts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateAuthorityServer).ListCerts(ctx, req.(*ListCertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertificateAuthority_WatchKRL_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchKRLRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CertificateAuthorityServer).WatchKRL(m, &certificateAuthorityWatchKRLServer{stream})
+}
+
+type CertificateAuthority_WatchKRLServer interface {
+	Send(*KRL) error
+	grpc.ServerStream
+}
+
+type certificateAuthorityWatchKRLServer struct {
+	grpc.ServerStream
+}
+
+func (x *certificateAuthorityWatchKRLServer) Send(m *KRL) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CertificateAuthority_ServiceDesc is the grpc.ServiceDesc for CertificateAuthority service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CertificateAuthority_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sshca.v1.CertificateAuthority",
+	HandlerType: (*CertificateAuthorityServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateChallenge",
+			Handler:    _CertificateAuthority_CreateChallenge_Handler,
+		},
+		{
+			MethodName: "Sign",
+			Handler:    _CertificateAuthority_Sign_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _CertificateAuthority_Revoke_Handler,
+		},
+		{
+			MethodName: "GetPublicKey",
+			Handler:    _CertificateAuthority_GetPublicKey_Handler,
+		},
+		{
+			MethodName: "ListCerts",
+			Handler:    _CertificateAuthority_ListCerts_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchKRL",
+			Handler:       _CertificateAuthority_WatchKRL_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ca.proto",
+}
@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/identity"
+)
+
+var ErrPolicyDenied = errs.New(errs.CodePolicyDenied, "requested principals or extensions are not granted to the identity")
+var ErrNoPrincipals = errs.New(errs.CodeNoPrincipals, "no principals granted")
+var ErrApprovalRequired = errors.New("request requires approval")
+
+// Request is what the policy decides on before the CA signs anything
+type Request struct {
+	// requester identity, the token name if the request is authorized by token only
+	Identity   string
+	Principals []string
+	TTL        time.Duration
+	Options    *ca.CertOptions
+	// approvers agreed on the request
+	Approved bool
+	// principals and options have been granted before, the identity is not resolved,
+	// e.g. renewal keeps those of the original certificate
+	Granted bool
+}
+
+// ApprovalRule holds back requests for sensitive principals or long lifetime until approved
+type ApprovalRule struct {
+	SensitivePrincipals []string
+	// zero means any lifetime
+	MaxTTL time.Duration
+	// number of distinct approvers required, out of Approvers (token ids) if it's not empty
+	Required  int
+	Approvers []string
+	// pending requests expire after
+	Expiry time.Duration
+}
+
+func (r *ApprovalRule) match(req *Request) bool {
+	if r.MaxTTL > 0 && req.TTL > r.MaxTTL {
+		return true
+	}
+
+	sensitive := make(map[string]bool, len(r.SensitivePrincipals))
+	for _, p := range r.SensitivePrincipals {
+		sensitive[p] = true
+	}
+
+	for _, p := range req.Principals {
+		if sensitive[p] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check the approver token is one of the configured approvers, anyone can approve if none is configured
+func (r *ApprovalRule) CanApprove(approverId string) bool {
+	if len(r.Approvers) == 0 {
+		return true
+	}
+
+	for _, a := range r.Approvers {
+		if a == approverId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Engine applies identity based rules to signing requests
+type Engine struct {
+	resolver identity.Resolver
+	approval *ApprovalRule
+	// approval rule may be replaced at runtime
+	lock *sync.RWMutex
+}
+
+func NewEngine(resolver identity.Resolver) *Engine {
+	return &Engine{resolver: resolver, lock: &sync.RWMutex{}}
+}
+
+// nil disables approval
+func (e *Engine) SetApprovalRule(rule *ApprovalRule) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.approval = rule
+}
+
+// nil if no request requires approval
+func (e *Engine) ApprovalRule() *ApprovalRule {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.approval
+}
+
+// check and complete the request in place.
+// principals and extensions of the identity are the resolved ones if none are requested,
+// otherwise requested ones must be granted to the identity. critical options resolved are forced.
+// resolutions may be cached and shared by requests, so they are copied rather than handed out
+func (e *Engine) Evaluate(req *Request) error {
+	if e.resolver != nil && !req.Granted {
+		if req.Identity == "" {
+			return identity.ErrUnknownIdentity
+		}
+
+		res, err := e.resolver.Resolve(req.Identity)
+		if err != nil {
+			return err
+		}
+
+		if len(req.Principals) == 0 {
+			req.Principals = append([]string(nil), res.Principals...)
+		} else if !subset(req.Principals, res.Principals) {
+			return ErrPolicyDenied
+		}
+
+		// requested extensions must be granted as well, granted ones are used if none is requested
+		if res.Extensions != nil {
+			if req.Options == nil {
+				req.Options = &ca.CertOptions{}
+			}
+
+			if req.Options.Extensions == nil {
+				req.Options.Extensions = copyMap(res.Extensions)
+			} else if !subset(keys(req.Options.Extensions), keys(res.Extensions)) {
+				return ErrPolicyDenied
+			}
+		}
+
+		if res.CriticalOptions != nil {
+			if req.Options == nil {
+				req.Options = &ca.CertOptions{}
+			}
+
+			forced := copyMap(req.Options.CriticalOptions)
+			for k, v := range res.CriticalOptions {
+				forced[k] = v
+			}
+			req.Options.CriticalOptions = forced
+		}
+	}
+
+	if len(req.Principals) == 0 {
+		return ErrNoPrincipals
+	}
+
+	// principals resolved above count as requested
+	if approval := e.ApprovalRule(); approval != nil && !req.Approved && approval.match(req) {
+		return ErrApprovalRequired
+	}
+
+	return nil
+}
+
+func subset(sub, set []string) bool {
+	granted := make(map[string]bool, len(set))
+	for _, s := range set {
+		granted[s] = true
+	}
+
+	for _, s := range sub {
+		if !granted[s] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func keys(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+
+	return res
+}
+
+func copyMap(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+
+	return res
+}
@@ -0,0 +1,50 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/identity"
+)
+
+// resolver handing out the same resolution every time, as a cached one does
+type staticResolver struct {
+	res *identity.Resolution
+}
+
+func (r *staticResolver) Resolve(id string) (*identity.Resolution, error) {
+	return r.res, nil
+}
+
+func TestEvaluateKeepsResolutionIntact(t *testing.T) {
+	res := &identity.Resolution{
+		Principals:      []string{"dev", "ops"},
+		Extensions:      map[string]string{"permit-pty": ""},
+		CriticalOptions: map[string]string{"source-address": "10.0.0.0/8"},
+	}
+	e := NewEngine(&staticResolver{res: res})
+
+	req := &Request{Identity: "alice"}
+	err := e.Evaluate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. the signer completing the request
+	req.Principals[0] = "root"
+	req.Options.Extensions["permit-port-forwarding"] = ""
+	req.Options.CriticalOptions["force-command"] = "/bin/true"
+
+	if strings.Join(res.Principals, ",") != "dev,ops" || len(res.Extensions) != 1 || len(res.CriticalOptions) != 1 {
+		t.Fatalf("resolution is changed through the request: %+v", res)
+	}
+
+	req = &Request{Identity: "alice"}
+	err = e.Evaluate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(req.Principals, ",") != "dev,ops" || len(req.Options.Extensions) != 1 || len(req.Options.CriticalOptions) != 1 {
+		t.Fatalf("unexpected request of the next evaluation %+v %+v", req, req.Options)
+	}
+}
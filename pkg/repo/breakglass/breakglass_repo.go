@@ -0,0 +1,26 @@
+package breakglass
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+type BreakGlassRepo interface {
+	CreateRecord(rec model.BreakGlass) error
+	GetRecords() ([]*model.BreakGlass, error)
+	// the most recent record, repo.ErrNotExist if none
+	GetLatestRecord() (*model.BreakGlass, error)
+	Close() error
+}
+
+func NewBreakGlassRepo(driver, dsn string) BreakGlassRepo {
+	switch driver {
+	case "memory":
+		return NewMemStore()
+	case "sqlite3":
+		return NewSqlRepo("sqlite", dsn)
+	case "mysql":
+		return NewSqlRepo("mysql", dsn)
+	}
+
+	return NewMemStore()
+}
@@ -0,0 +1,61 @@
+package breakglass
+
+import (
+	"sync"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+)
+
+type MemStore struct {
+	// in creation order
+	store []model.BreakGlass
+	lock  *sync.Mutex
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		store: make([]model.BreakGlass, 0),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (m *MemStore) CreateRecord(rec model.BreakGlass) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.store = append(m.store, rec)
+
+	return nil
+}
+
+func (m *MemStore) GetRecords() ([]*model.BreakGlass, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := make([]*model.BreakGlass, 0, len(m.store))
+
+	for _, r := range m.store {
+		r := r
+		res = append(res, &r)
+	}
+
+	return res, nil
+}
+
+func (m *MemStore) GetLatestRecord() (*model.BreakGlass, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(m.store) == 0 {
+		return nil, repo.ErrNotExist
+	}
+
+	latest := m.store[len(m.store)-1]
+
+	return &latest, nil
+}
+
+func (m *MemStore) Close() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package breakglass
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+type stmts struct {
+	createRecord    *sqlx.Stmt
+	getAllRecords   *sqlx.Stmt
+	getLatestRecord *sqlx.Stmt
+}
+
+type SqlStore struct {
+	preparedStmts *stmts
+	db            *sqlx.DB
+}
+
+func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
+	stmt = &stmts{}
+
+	stmt.createRecord, err = db.Preparex("INSERT INTO break_glass (id, keyid, requester, principal, justification, ticket, client_ip, created_at, valid_end) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	stmt.getAllRecords, err = db.Preparex("SELECT * FROM break_glass ORDER BY created_at")
+	if err != nil {
+		return
+	}
+
+	stmt.getLatestRecord, err = db.Preparex("SELECT * FROM break_glass ORDER BY created_at DESC LIMIT 1")
+	if err != nil {
+		return
+	}
+
+	return
+
+}
+
+func NewSqlRepo(sqldriver, dsn string) *SqlStore {
+	db, err := sqlx.Connect(sqldriver, dsn)
+	if err != nil {
+		panic(err)
+	}
+
+	stmt, err := prepareStmts(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ret := &SqlStore{
+		db:            db,
+		preparedStmts: stmt,
+	}
+
+	err = ret.migration()
+	if err != nil {
+		panic(err)
+	}
+
+	return ret
+
+}
+
+func (ss *SqlStore) migration() error {
+	// make sure table exist
+	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS break_glass (id VARCHAR(50) PRIMARY KEY, keyid VARCHAR(512), requester VARCHAR(255), principal VARCHAR(255), justification TEXT, ticket VARCHAR(255), client_ip VARCHAR(64), created_at DATETIME, valid_end DATETIME)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ss *SqlStore) CreateRecord(rec model.BreakGlass) error {
+	_, err := ss.preparedStmts.createRecord.Exec(rec.Id, rec.KeyId, rec.Requester, rec.Principal, rec.Justification,
+		rec.Ticket, rec.ClientIP, rec.CreatedAt, rec.ValidEnd)
+
+	return err
+}
+
+func (ss *SqlStore) GetRecords() ([]*model.BreakGlass, error) {
+	res := make([]*model.BreakGlass, 0)
+	err := ss.preparedStmts.getAllRecords.Select(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) GetLatestRecord() (*model.BreakGlass, error) {
+	res := &model.BreakGlass{}
+	err := ss.preparedStmts.getLatestRecord.Get(res)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) Close() error {
+	return ss.db.Close()
+}
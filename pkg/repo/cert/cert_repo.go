@@ -6,9 +6,10 @@ import (
 
 type CertRepo interface {
 	CreateCert(cert model.Cert) error
-	UpdateRevoke(certId string, revoked bool) error
+	// repo.ErrNotExist if there is no certificate of the role with the id
+	UpdateRevoke(role model.RoleType, certId string, revoked bool) error
 	GetCertsByRole(role model.RoleType) ([]*model.Cert, error)
-	// GetCertsById(id string) ([]*model.Cert, error)
+	GetCertById(id string) (*model.Cert, error)
 	GetRevokedCertIdsByRole(role model.RoleType) ([]string, error)
 	GetExpiredCertIdsByRole(role model.RoleType) ([]string, error)
 	Close() error
@@ -29,12 +29,12 @@ func (m *MemStore) CreateCert(cert model.Cert) error {
 	return nil
 }
 
-func (m *MemStore) UpdateRevoke(certId string, revoked bool) error {
+func (m *MemStore) UpdateRevoke(role model.RoleType, certId string, revoked bool) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
 	c, exist := m.store[certId]
-	if !exist {
+	if !exist || c.Type != role {
 		return repo.ErrNotExist
 	}
 
@@ -51,6 +51,7 @@ func (m *MemStore) GetCertsByRole(role model.RoleType) ([]*model.Cert, error) {
 	res := make([]*model.Cert, 0)
 
 	for _, c := range m.store {
+		c := c
 		if c.Type == role {
 			res = append(res, &c)
 		}
@@ -59,6 +60,18 @@ func (m *MemStore) GetCertsByRole(role model.RoleType) ([]*model.Cert, error) {
 	return res, nil
 }
 
+func (m *MemStore) GetCertById(id string) (*model.Cert, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, exist := m.store[id]
+	if !exist {
+		return nil, repo.ErrNotExist
+	}
+
+	return &c, nil
+}
+
 func (m *MemStore) GetRevokedCertIdsByRole(role model.RoleType) ([]string, error) {
 	certs, err := m.GetCertsByRole(role)
 	if err != nil {
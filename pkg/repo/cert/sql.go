@@ -1,9 +1,12 @@
 package cert
 
 import (
+	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
 	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
 )
@@ -11,6 +14,7 @@ import (
 type stmts struct {
 	createCert               *sqlx.Stmt
 	getAllCertsByRole        *sqlx.Stmt
+	getCertById              *sqlx.Stmt
 	getAllRevokedCertsByRole *sqlx.Stmt
 	getAllExpiredCertsByRole *sqlx.Stmt
 	updateRevoked            *sqlx.Stmt
@@ -24,7 +28,7 @@ type SqlStore struct {
 func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
 	stmt = &stmts{}
 
-	stmt.createCert, err = db.Preparex("INSERT INTO certs (keyid, type, valid_start, valid_end, content, revoked) VALUES (?, ?, ?, ?, ?, ?)")
+	stmt.createCert, err = db.Preparex("INSERT INTO certs (keyid, type, valid_start, valid_end, content, revoked, break_glass) VALUES (?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return
 	}
@@ -34,6 +38,11 @@ func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
 		return
 	}
 
+	stmt.getCertById, err = db.Preparex("SELECT * FROM certs WHERE keyid = ?")
+	if err != nil {
+		return
+	}
+
 	stmt.getAllRevokedCertsByRole, err = db.Preparex("SELECT keyid FROM certs WHERE type = ? AND revoked = 1")
 	if err != nil {
 		return
@@ -44,7 +53,7 @@ func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
 		return
 	}
 
-	stmt.updateRevoked, err = db.Preparex("UPDATE certs SET revoked = ? WHERE keyid = ?")
+	stmt.updateRevoked, err = db.Preparex("UPDATE certs SET revoked = ? WHERE keyid = ? AND type = ?")
 	if err != nil {
 		return
 	}
@@ -80,11 +89,28 @@ func NewSqlRepo(sqldriver, dsn string) *SqlStore {
 
 func (ss *SqlStore) migration() error {
 	// make sure table exist
-	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS certs (keyid VARCHAR(50) PRIMARY KEY, type TINYINT, valid_start DATETIME, valid_end DATETIME, content TEXT, revoked BOOLEAN)")
+	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS certs (keyid VARCHAR(512) PRIMARY KEY, type TINYINT, valid_start DATETIME, valid_end DATETIME, content TEXT, revoked BOOLEAN, break_glass BOOLEAN DEFAULT FALSE)")
 	if err != nil {
 		return err
 	}
 
+	// key ids rendered from templates outgrow the width of older tables, sqlite doesn't enforce it
+	if ss.db.DriverName() == "mysql" {
+		_, err = ss.db.Exec("ALTER TABLE certs MODIFY keyid VARCHAR(512)")
+		if err != nil {
+			return err
+		}
+	}
+
+	// older tables have no break-glass flag
+	_, err = ss.db.Exec("SELECT break_glass FROM certs LIMIT 1")
+	if err != nil {
+		_, err = ss.db.Exec("ALTER TABLE certs ADD COLUMN break_glass BOOLEAN DEFAULT FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
 	// make index
 	_, err = ss.db.Exec("CREATE INDEX IF NOT EXISTS idx_role_revoke ON certs(type, revoked)")
 	if err != nil {
@@ -95,15 +121,22 @@ func (ss *SqlStore) migration() error {
 }
 
 func (ss *SqlStore) CreateCert(cert model.Cert) error {
-	_, err := ss.preparedStmts.createCert.Exec(cert.KeyId, cert.Type, cert.ValidStart, cert.ValidEnd, cert.Content, cert.Revoked)
+	_, err := ss.preparedStmts.createCert.Exec(cert.KeyId, cert.Type, cert.ValidStart, cert.ValidEnd, cert.Content, cert.Revoked, cert.BreakGlass)
 
 	return err
 }
 
-func (ss *SqlStore) UpdateRevoke(certId string, revoked bool) error {
-	_, err := ss.preparedStmts.updateRevoked.Exec(revoked, certId)
+func (ss *SqlStore) UpdateRevoke(role model.RoleType, certId string, revoked bool) error {
+	r, err := ss.preparedStmts.updateRevoked.Exec(revoked, certId, role)
+	if err != nil {
+		return err
+	}
+
+	if n, err := r.RowsAffected(); err == nil && n == 0 {
+		return repo.ErrNotExist
+	}
 
-	return err
+	return nil
 }
 
 func (ss *SqlStore) GetCertsByRole(role model.RoleType) ([]*model.Cert, error) {
@@ -116,6 +149,19 @@ func (ss *SqlStore) GetCertsByRole(role model.RoleType) ([]*model.Cert, error) {
 	return res, nil
 }
 
+func (ss *SqlStore) GetCertById(id string) (*model.Cert, error) {
+	res := &model.Cert{}
+	err := ss.preparedStmts.getCertById.Get(res, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (ss *SqlStore) GetRevokedCertIdsByRole(role model.RoleType) ([]string, error) {
 	res := make([]string, 0)
 	err := ss.preparedStmts.getAllRevokedCertsByRole.Select(&res, role)
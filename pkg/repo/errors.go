@@ -1,5 +1,5 @@
 package repo
 
-import "errors"
+import "github.com/0w0mewo/ssh_cert_ca/pkg/errs"
 
-var ErrNotExist = errors.New("not such record")
+var ErrNotExist = errs.New(errs.CodeNotFound, "not such record")
@@ -0,0 +1,25 @@
+package importedkrl
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+type ImportedKRLRepo interface {
+	// replaces the record of the same content
+	SaveKRL(k model.ImportedKRL) error
+	GetKRLsByRole(role model.RoleType) ([]*model.ImportedKRL, error)
+	Close() error
+}
+
+func NewImportedKRLRepo(driver, dsn string) ImportedKRLRepo {
+	switch driver {
+	case "memory":
+		return NewMemStore()
+	case "sqlite3":
+		return NewSqlRepo("sqlite", dsn)
+	case "mysql":
+		return NewSqlRepo("mysql", dsn)
+	}
+
+	return NewMemStore()
+}
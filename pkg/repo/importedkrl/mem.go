@@ -0,0 +1,55 @@
+package importedkrl
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+type MemStore struct {
+	store map[string]model.ImportedKRL
+	lock  *sync.Mutex
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		store: make(map[string]model.ImportedKRL),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (m *MemStore) SaveKRL(k model.ImportedKRL) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.store[k.Id] = k
+
+	return nil
+}
+
+func (m *MemStore) GetKRLsByRole(role model.RoleType) ([]*model.ImportedKRL, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := make([]*model.ImportedKRL, 0)
+
+	for _, k := range m.store {
+		if k.Type != role {
+			continue
+		}
+
+		k := k
+		res = append(res, &k)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ImportedAt.Before(res[j].ImportedAt)
+	})
+
+	return res, nil
+}
+
+func (m *MemStore) Close() error {
+	return nil
+}
@@ -0,0 +1,89 @@
+package importedkrl
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+type stmts struct {
+	saveKRL       *sqlx.Stmt
+	getKRLsByRole *sqlx.Stmt
+}
+
+type SqlStore struct {
+	preparedStmts *stmts
+	db            *sqlx.DB
+}
+
+func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
+	stmt = &stmts{}
+
+	stmt.saveKRL, err = db.Preparex("REPLACE INTO imported_krls (id, type, content, imported_at) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	stmt.getKRLsByRole, err = db.Preparex("SELECT * FROM imported_krls WHERE type = ? ORDER BY imported_at")
+	if err != nil {
+		return
+	}
+
+	return
+
+}
+
+func NewSqlRepo(sqldriver, dsn string) *SqlStore {
+	db, err := sqlx.Connect(sqldriver, dsn)
+	if err != nil {
+		panic(err)
+	}
+
+	ret := &SqlStore{
+		db: db,
+	}
+
+	// statements are prepared against the table
+	err = ret.migration()
+	if err != nil {
+		panic(err)
+	}
+
+	ret.preparedStmts, err = prepareStmts(db)
+	if err != nil {
+		panic(err)
+	}
+
+	return ret
+
+}
+
+func (ss *SqlStore) migration() error {
+	// make sure table exist
+	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS imported_krls (id VARCHAR(64) PRIMARY KEY, type TINYINT, content MEDIUMBLOB, imported_at DATETIME)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ss *SqlStore) SaveKRL(k model.ImportedKRL) error {
+	_, err := ss.preparedStmts.saveKRL.Exec(k.Id, k.Type, k.Content, k.ImportedAt)
+
+	return err
+}
+
+func (ss *SqlStore) GetKRLsByRole(role model.RoleType) ([]*model.ImportedKRL, error) {
+	res := make([]*model.ImportedKRL, 0)
+	err := ss.preparedStmts.getKRLsByRole.Select(&res, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) Close() error {
+	return ss.db.Close()
+}
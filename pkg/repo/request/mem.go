@@ -0,0 +1,116 @@
+package request
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+)
+
+type MemStore struct {
+	store map[string]model.CertRequest
+	lock  *sync.Mutex
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		store: make(map[string]model.CertRequest),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (m *MemStore) CreateRequest(req model.CertRequest) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	req.Approvers = nil
+	m.store[req.Id] = req
+
+	return nil
+}
+
+func (m *MemStore) GetRequestById(id string) (*model.CertRequest, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	req, exist := m.store[id]
+	if !exist {
+		return nil, repo.ErrNotExist
+	}
+	req.Approvers = append([]string(nil), req.Approvers...)
+
+	return &req, nil
+}
+
+func (m *MemStore) GetRequestsByStatus(status string) ([]*model.CertRequest, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := make([]*model.CertRequest, 0)
+
+	for _, r := range m.store {
+		r := r
+		if status == "" || r.Status == status {
+			r.Approvers = append([]string(nil), r.Approvers...)
+			res = append(res, &r)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *MemStore) UpdateRequest(req model.CertRequest, status string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	r, exist := m.store[req.Id]
+	if !exist || r.Status != status {
+		return repo.ErrNotExist
+	}
+
+	r.Status, r.DeniedBy, r.CertKeyId = req.Status, req.DeniedBy, req.CertKeyId
+	m.store[req.Id] = r
+
+	return nil
+}
+
+func (m *MemStore) AddApprover(id, approverId string, at time.Time) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	r, exist := m.store[id]
+	if !exist {
+		return repo.ErrNotExist
+	}
+
+	if r.ApprovedBy(approverId) {
+		return ErrDuplicateApprover
+	}
+
+	r.Approvers = append(append([]string(nil), r.Approvers...), approverId)
+	m.store[id] = r
+
+	return nil
+}
+
+func (m *MemStore) ExpirePendingRequests(before time.Time) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ids := make([]string, 0)
+
+	for id, r := range m.store {
+		if r.Status == model.RequestPending && r.ExpiresAt.Before(before) {
+			r.Status = model.RequestExpired
+			m.store[id] = r
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (m *MemStore) Close() error {
+	return nil
+}
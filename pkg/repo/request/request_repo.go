@@ -0,0 +1,39 @@
+package request
+
+import (
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+)
+
+var ErrDuplicateApprover = errs.New(errs.CodeAlreadyApproved, "request is already approved by the approver")
+
+type CertRequestRepo interface {
+	CreateRequest(req model.CertRequest) error
+	// approvers of the request included
+	GetRequestById(id string) (*model.CertRequest, error)
+	// all requests if status is empty
+	GetRequestsByStatus(status string) ([]*model.CertRequest, error)
+	// update status, denier and certificate key id of the request if it's still in the given status,
+	// repo.ErrNotExist otherwise
+	UpdateRequest(req model.CertRequest, status string) error
+	// ErrDuplicateApprover if the approver has approved the request already
+	AddApprover(id, approverId string, at time.Time) error
+	// mark pending requests expired before the given time, returns ids of them
+	ExpirePendingRequests(before time.Time) ([]string, error)
+	Close() error
+}
+
+func NewCertRequestRepo(driver, dsn string) CertRequestRepo {
+	switch driver {
+	case "memory":
+		return NewMemStore()
+	case "sqlite3":
+		return NewSqlRepo("sqlite", dsn)
+	case "mysql":
+		return NewSqlRepo("mysql", dsn)
+	}
+
+	return NewMemStore()
+}
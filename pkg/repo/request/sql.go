@@ -0,0 +1,232 @@
+package request
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+const requestColumns = "id, type, requester, requester_id, client_ip, identity, pubkey, principals, ttl, serial, options, status, required, denied_by, cert_keyid, created_at, expires_at"
+
+type stmts struct {
+	createRequest       *sqlx.Stmt
+	getRequestById      *sqlx.Stmt
+	getAllRequests      *sqlx.Stmt
+	getRequestsByStatus *sqlx.Stmt
+	updateRequest       *sqlx.Stmt
+	getExpiredPending   *sqlx.Stmt
+	expirePending       *sqlx.Stmt
+	getApprovers        *sqlx.Stmt
+	countApproval       *sqlx.Stmt
+	addApproval         *sqlx.Stmt
+}
+
+type SqlStore struct {
+	preparedStmts *stmts
+	db            *sqlx.DB
+}
+
+func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
+	stmt = &stmts{}
+
+	stmt.createRequest, err = db.Preparex("INSERT INTO cert_requests (" + requestColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	stmt.getRequestById, err = db.Preparex("SELECT " + requestColumns + " FROM cert_requests WHERE id = ?")
+	if err != nil {
+		return
+	}
+
+	stmt.getAllRequests, err = db.Preparex("SELECT " + requestColumns + " FROM cert_requests ORDER BY created_at")
+	if err != nil {
+		return
+	}
+
+	stmt.getRequestsByStatus, err = db.Preparex("SELECT " + requestColumns + " FROM cert_requests WHERE status = ? ORDER BY created_at")
+	if err != nil {
+		return
+	}
+
+	stmt.updateRequest, err = db.Preparex("UPDATE cert_requests SET status = ?, denied_by = ?, cert_keyid = ? WHERE id = ? AND status = ?")
+	if err != nil {
+		return
+	}
+
+	stmt.getExpiredPending, err = db.Preparex("SELECT id FROM cert_requests WHERE status = ? AND expires_at < ?")
+	if err != nil {
+		return
+	}
+
+	stmt.expirePending, err = db.Preparex("UPDATE cert_requests SET status = ? WHERE status = ? AND expires_at < ?")
+	if err != nil {
+		return
+	}
+
+	stmt.getApprovers, err = db.Preparex("SELECT approver_id FROM cert_request_approvals WHERE request_id = ? ORDER BY approved_at")
+	if err != nil {
+		return
+	}
+
+	stmt.countApproval, err = db.Preparex("SELECT COUNT(*) FROM cert_request_approvals WHERE request_id = ? AND approver_id = ?")
+	if err != nil {
+		return
+	}
+
+	stmt.addApproval, err = db.Preparex("INSERT INTO cert_request_approvals (request_id, approver_id, approved_at) VALUES (?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	return
+
+}
+
+func NewSqlRepo(sqldriver, dsn string) *SqlStore {
+	db, err := sqlx.Connect(sqldriver, dsn)
+	if err != nil {
+		panic(err)
+	}
+
+	stmt, err := prepareStmts(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ret := &SqlStore{
+		db:            db,
+		preparedStmts: stmt,
+	}
+
+	err = ret.migration()
+	if err != nil {
+		panic(err)
+	}
+
+	return ret
+
+}
+
+func (ss *SqlStore) migration() error {
+	// make sure table exist
+	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS cert_requests (id VARCHAR(50) PRIMARY KEY, type INTEGER, requester VARCHAR(255), requester_id VARCHAR(255), client_ip VARCHAR(64), identity VARCHAR(255), pubkey TEXT, principals TEXT, ttl INTEGER, serial BIGINT, options TEXT, status VARCHAR(16), required INTEGER, denied_by VARCHAR(255), cert_keyid VARCHAR(255), created_at DATETIME, expires_at DATETIME)")
+	if err != nil {
+		return err
+	}
+
+	// an approver counts once per request
+	_, err = ss.db.Exec("CREATE TABLE IF NOT EXISTS cert_request_approvals (request_id VARCHAR(50), approver_id VARCHAR(255), approved_at DATETIME, PRIMARY KEY (request_id, approver_id))")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ss *SqlStore) CreateRequest(req model.CertRequest) error {
+	_, err := ss.preparedStmts.createRequest.Exec(req.Id, req.Type, req.Requester, req.RequesterId, req.ClientIP, req.Identity, req.PublicKey, req.Principals,
+		req.TTL, req.Serial, req.Options, req.Status, req.Required, req.DeniedBy, req.CertKeyId, req.CreatedAt, req.ExpiresAt)
+
+	return err
+}
+
+func (ss *SqlStore) GetRequestById(id string) (*model.CertRequest, error) {
+	res := &model.CertRequest{}
+	err := ss.preparedStmts.getRequestById.Get(res, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = ss.loadApprovers(res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) GetRequestsByStatus(status string) ([]*model.CertRequest, error) {
+	res := make([]*model.CertRequest, 0)
+
+	var err error
+	if status == "" {
+		err = ss.preparedStmts.getAllRequests.Select(&res)
+	} else {
+		err = ss.preparedStmts.getRequestsByStatus.Select(&res, status)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range res {
+		err = ss.loadApprovers(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) loadApprovers(req *model.CertRequest) error {
+	req.Approvers = make([]string, 0)
+	return ss.preparedStmts.getApprovers.Select(&req.Approvers, req.Id)
+}
+
+func (ss *SqlStore) UpdateRequest(req model.CertRequest, status string) error {
+	r, err := ss.preparedStmts.updateRequest.Exec(req.Status, req.DeniedBy, req.CertKeyId, req.Id, status)
+	if err != nil {
+		return err
+	}
+
+	if n, err := r.RowsAffected(); err == nil && n == 0 {
+		return repo.ErrNotExist
+	}
+
+	return nil
+}
+
+// primary key of the approval refuses duplicates racing past the check
+func (ss *SqlStore) AddApprover(id, approverId string, at time.Time) error {
+	var n int
+	err := ss.preparedStmts.countApproval.Get(&n, id, approverId)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrDuplicateApprover
+	}
+
+	_, err = ss.preparedStmts.addApproval.Exec(id, approverId, at)
+
+	return err
+}
+
+func (ss *SqlStore) ExpirePendingRequests(before time.Time) ([]string, error) {
+	ids := make([]string, 0)
+
+	err := ss.preparedStmts.getExpiredPending.Select(&ids, model.RequestPending, before)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ss.preparedStmts.expirePending.Exec(model.RequestExpired, model.RequestPending, before)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (ss *SqlStore) Close() error {
+	return ss.db.Close()
+}
@@ -0,0 +1,59 @@
+package revocation
+
+import (
+	"sync"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+)
+
+type MemStore struct {
+	store map[string]model.Revocation
+	lock  *sync.Mutex
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		store: make(map[string]model.Revocation),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (m *MemStore) SaveRevocation(rev model.Revocation) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.store[rev.KeyId] = rev
+
+	return nil
+}
+
+func (m *MemStore) GetRevocationById(keyid string) (*model.Revocation, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	rev, exist := m.store[keyid]
+	if !exist {
+		return nil, repo.ErrNotExist
+	}
+
+	return &rev, nil
+}
+
+func (m *MemStore) GetRevocations() ([]*model.Revocation, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := make([]*model.Revocation, 0, len(m.store))
+
+	for _, r := range m.store {
+		r := r
+		res = append(res, &r)
+	}
+
+	return res, nil
+}
+
+func (m *MemStore) Close() error {
+	return nil
+}
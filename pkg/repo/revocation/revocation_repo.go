@@ -0,0 +1,26 @@
+package revocation
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+type RevocationRepo interface {
+	// replaces the record of the same certificate
+	SaveRevocation(rev model.Revocation) error
+	GetRevocationById(keyid string) (*model.Revocation, error)
+	GetRevocations() ([]*model.Revocation, error)
+	Close() error
+}
+
+func NewRevocationRepo(driver, dsn string) RevocationRepo {
+	switch driver {
+	case "memory":
+		return NewMemStore()
+	case "sqlite3":
+		return NewSqlRepo("sqlite", dsn)
+	case "mysql":
+		return NewSqlRepo("mysql", dsn)
+	}
+
+	return NewMemStore()
+}
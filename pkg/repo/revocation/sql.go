@@ -0,0 +1,112 @@
+package revocation
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+type stmts struct {
+	saveRevocation    *sqlx.Stmt
+	getRevocationById *sqlx.Stmt
+	getAllRevocations *sqlx.Stmt
+}
+
+type SqlStore struct {
+	preparedStmts *stmts
+	db            *sqlx.DB
+}
+
+func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
+	stmt = &stmts{}
+
+	stmt.saveRevocation, err = db.Preparex("REPLACE INTO revocations (keyid, reason, revoked_by, revoked_at) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	stmt.getRevocationById, err = db.Preparex("SELECT * FROM revocations WHERE keyid = ?")
+	if err != nil {
+		return
+	}
+
+	stmt.getAllRevocations, err = db.Preparex("SELECT * FROM revocations ORDER BY revoked_at")
+	if err != nil {
+		return
+	}
+
+	return
+
+}
+
+func NewSqlRepo(sqldriver, dsn string) *SqlStore {
+	db, err := sqlx.Connect(sqldriver, dsn)
+	if err != nil {
+		panic(err)
+	}
+
+	ret := &SqlStore{
+		db: db,
+	}
+
+	// statements are prepared against the table
+	err = ret.migration()
+	if err != nil {
+		panic(err)
+	}
+
+	ret.preparedStmts, err = prepareStmts(db)
+	if err != nil {
+		panic(err)
+	}
+
+	return ret
+
+}
+
+func (ss *SqlStore) migration() error {
+	// make sure table exist
+	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS revocations (keyid VARCHAR(512) PRIMARY KEY, reason TEXT, revoked_by VARCHAR(255), revoked_at DATETIME)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ss *SqlStore) SaveRevocation(rev model.Revocation) error {
+	_, err := ss.preparedStmts.saveRevocation.Exec(rev.KeyId, rev.Reason, rev.RevokedBy, rev.RevokedAt)
+
+	return err
+}
+
+func (ss *SqlStore) GetRevocationById(keyid string) (*model.Revocation, error) {
+	res := &model.Revocation{}
+	err := ss.preparedStmts.getRevocationById.Get(res, keyid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) GetRevocations() ([]*model.Revocation, error) {
+	res := make([]*model.Revocation, 0)
+	err := ss.preparedStmts.getAllRevocations.Select(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) Close() error {
+	return ss.db.Close()
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"sync"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+)
+
+type MemStore struct {
+	store map[string]model.Token
+	lock  *sync.Mutex
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		store: make(map[string]model.Token),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (m *MemStore) CreateToken(token model.Token) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.store[token.Id] = token
+
+	return nil
+}
+
+func (m *MemStore) GetTokenByHash(hash string) (*model.Token, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, t := range m.store {
+		if t.Hash == hash {
+			found := t
+			return &found, nil
+		}
+	}
+
+	return nil, repo.ErrNotExist
+}
+
+func (m *MemStore) GetTokens() ([]*model.Token, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := make([]*model.Token, 0)
+
+	for _, t := range m.store {
+		t := t
+		res = append(res, &t)
+	}
+
+	return res, nil
+}
+
+func (m *MemStore) DeleteToken(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, exist := m.store[id]; !exist {
+		return repo.ErrNotExist
+	}
+
+	delete(m.store, id)
+
+	return nil
+}
+
+func (m *MemStore) Close() error {
+	return nil
+}
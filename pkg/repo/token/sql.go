@@ -0,0 +1,131 @@
+package token
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+type stmts struct {
+	createToken    *sqlx.Stmt
+	getTokenByHash *sqlx.Stmt
+	getAllTokens   *sqlx.Stmt
+	deleteToken    *sqlx.Stmt
+}
+
+type SqlStore struct {
+	preparedStmts *stmts
+	db            *sqlx.DB
+}
+
+func prepareStmts(db *sqlx.DB) (stmt *stmts, err error) {
+	stmt = &stmts{}
+
+	stmt.createToken, err = db.Preparex("INSERT INTO tokens (id, name, hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	stmt.getTokenByHash, err = db.Preparex("SELECT * FROM tokens WHERE hash = ?")
+	if err != nil {
+		return
+	}
+
+	stmt.getAllTokens, err = db.Preparex("SELECT * FROM tokens")
+	if err != nil {
+		return
+	}
+
+	stmt.deleteToken, err = db.Preparex("DELETE FROM tokens WHERE id = ?")
+	if err != nil {
+		return
+	}
+
+	return
+
+}
+
+func NewSqlRepo(sqldriver, dsn string) *SqlStore {
+	db, err := sqlx.Connect(sqldriver, dsn)
+	if err != nil {
+		panic(err)
+	}
+
+	stmt, err := prepareStmts(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ret := &SqlStore{
+		db:            db,
+		preparedStmts: stmt,
+	}
+
+	err = ret.migration()
+	if err != nil {
+		panic(err)
+	}
+
+	return ret
+
+}
+
+func (ss *SqlStore) migration() error {
+	// make sure table exist
+	_, err := ss.db.Exec("CREATE TABLE IF NOT EXISTS tokens (id VARCHAR(50) PRIMARY KEY, name VARCHAR(255), hash VARCHAR(64) UNIQUE, scopes VARCHAR(255), created_at DATETIME, expires_at DATETIME)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ss *SqlStore) CreateToken(token model.Token) error {
+	_, err := ss.preparedStmts.createToken.Exec(token.Id, token.Name, token.Hash, token.Scopes, token.CreatedAt, token.ExpiresAt)
+
+	return err
+}
+
+func (ss *SqlStore) GetTokenByHash(hash string) (*model.Token, error) {
+	res := &model.Token{}
+	err := ss.preparedStmts.getTokenByHash.Get(res, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) GetTokens() ([]*model.Token, error) {
+	res := make([]*model.Token, 0)
+	err := ss.preparedStmts.getAllTokens.Select(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (ss *SqlStore) DeleteToken(id string) error {
+	r, err := ss.preparedStmts.deleteToken.Exec(id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := r.RowsAffected(); err == nil && n == 0 {
+		return repo.ErrNotExist
+	}
+
+	return nil
+}
+
+func (ss *SqlStore) Close() error {
+	return ss.db.Close()
+}
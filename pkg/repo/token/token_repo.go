@@ -0,0 +1,26 @@
+package token
+
+import (
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+type TokenRepo interface {
+	CreateToken(token model.Token) error
+	GetTokenByHash(hash string) (*model.Token, error)
+	GetTokens() ([]*model.Token, error)
+	DeleteToken(id string) error
+	Close() error
+}
+
+func NewTokenRepo(driver, dsn string) TokenRepo {
+	switch driver {
+	case "memory":
+		return NewMemStore()
+	case "sqlite3":
+		return NewSqlRepo("sqlite", dsn)
+	case "mysql":
+		return NewSqlRepo("mysql", dsn)
+	}
+
+	return NewMemStore()
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/request"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrRequestNotPending = errs.New(errs.CodeRequestNotPending, "request is not pending")
+var ErrSelfApproval = errs.New(errs.CodeNotApprover, "requester can not approve own request")
+var ErrAlreadyApproved = request.ErrDuplicateApprover
+var ErrNotApprover = errs.New(errs.CodeNotApprover, "not an approver of the CA")
+var ErrNoApprovalRule = errs.New(errs.CodeNotFound, "CA does not require approval")
+
+// default lifetime of pending requests
+const defaultRequestExpiry = 24 * time.Hour
+
+// ApprovalService holds signing requests back until enough approvers agreed on them
+type ApprovalService struct {
+	store request.CertRequestRepo
+	cas   map[model.RoleType]*SSHCertCAService
+	tasks *utils.ScheduledTaskGroup
+	// serialise decisions so a request is never issued twice
+	lock *sync.Mutex
+}
+
+func NewApprovalService(dbdriver, dsn string, cas ...*SSHCertCAService) *ApprovalService {
+	ret := &ApprovalService{
+		store: request.NewCertRequestRepo(dbdriver, dsn),
+		cas:   make(map[model.RoleType]*SSHCertCAService),
+		tasks: utils.NewScheduledTaskGroup("approval"),
+		lock:  &sync.Mutex{},
+	}
+
+	for _, c := range cas {
+		ret.cas[c.Role()] = c
+	}
+
+	ret.tasks.AddPerodical(1*time.Minute, func() error {
+		return ret.taskExpirePendingRequests()
+	})
+
+	return ret
+}
+
+func (s *ApprovalService) rule(role model.RoleType) (*policy.ApprovalRule, *SSHCertCAService, error) {
+	c, ok := s.cas[role]
+	if !ok {
+		return nil, nil, model.ErrUnsupportedCertType
+	}
+
+	if c.Policy() == nil || c.Policy().ApprovalRule() == nil {
+		return nil, nil, ErrNoApprovalRule
+	}
+
+	return c.Policy().ApprovalRule(), c, nil
+}
+
+// store the request which was refused with policy.ErrApprovalRequired
+func (s *ApprovalService) Submit(role model.RoleType, req *SignRequest) (*model.CertRequest, error) {
+	rule, _, err := s.rule(role)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []byte
+	if req.Options != nil {
+		opts, err = json.Marshal(req.Options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	expiry := rule.Expiry
+	if expiry <= 0 {
+		expiry = defaultRequestExpiry
+	}
+
+	if req.Serial == 0 {
+		req.Serial = randomSerial()
+	}
+
+	now := time.Now()
+	cr := model.CertRequest{
+		Id:          uuid.NewString(),
+		Type:        role,
+		Requester:   req.Requester,
+		RequesterId: req.RequesterId,
+		ClientIP:    req.ClientIP,
+		Identity:    req.Identity,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(req.PublicKey))),
+		Principals:  strings.Join(req.Principals, ","),
+		TTL:         uint64(req.TTL / time.Second),
+		Serial:      req.Serial,
+		Options:     string(opts),
+		CertKeyId:   req.KeyId,
+		Status:      model.RequestPending,
+		Required:    rule.Required,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(expiry),
+	}
+
+	if cr.Required <= 0 {
+		cr.Required = 1
+	}
+
+	err = s.store.CreateRequest(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cr, nil
+}
+
+// the request, along with its certificate once issued if it's asked by the requester
+func (s *ApprovalService) Get(id, tokenId string) (*model.CertRequest, *model.Cert, error) {
+	cr, err := s.store.GetRequestById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cr.Status != model.RequestIssued || cr.RequesterId != tokenId {
+		return cr, nil, nil
+	}
+
+	c, ok := s.cas[cr.Type]
+	if !ok {
+		return nil, nil, model.ErrUnsupportedCertType
+	}
+
+	cert, err := c.GetCert(cr.CertKeyId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cr, cert, nil
+}
+
+// all requests if status is empty
+func (s *ApprovalService) List(status string) ([]*model.CertRequest, error) {
+	return s.store.GetRequestsByStatus(status)
+}
+
+// record the approval of the token, the certificate is issued once enough approvers agreed.
+// approving again retries issuing if it failed before
+func (s *ApprovalService) Approve(id, approverId string) (*model.CertRequest, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cr, c, err := s.pendingRequest(id, approverId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cr.ApprovedBy(approverId) {
+		err = s.store.AddApprover(cr.Id, approverId, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		cr.Approvers = append(cr.Approvers, approverId)
+	} else if len(cr.Approvers) < cr.Required {
+		return nil, ErrAlreadyApproved
+	}
+
+	if len(cr.Approvers) < cr.Required {
+		return cr, nil
+	}
+
+	err = s.issue(cr, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// the request is marked issued before signing, so it's never issued twice whatever fails afterwards
+func (s *ApprovalService) issue(cr *model.CertRequest, c *SSHCertCAService) error {
+	req, err := approvedSignRequest(cr)
+	if err != nil {
+		return err
+	}
+
+	cr.Status = model.RequestIssued
+	err = s.store.UpdateRequest(*cr, model.RequestPending)
+	if errors.Is(err, repo.ErrNotExist) {
+		return ErrRequestNotPending
+	}
+	if err != nil {
+		return err
+	}
+
+	cert, err := c.Issue(req)
+	if err != nil {
+		// nothing is signed, leave it to be approved again
+		cr.Status = model.RequestPending
+		if rerr := s.store.UpdateRequest(*cr, model.RequestIssued); rerr != nil {
+			log.Printf("request %s is left issued without certificate: %v", cr.Id, rerr)
+		}
+
+		return err
+	}
+
+	cr.CertKeyId = cert.KeyId
+
+	return s.store.UpdateRequest(*cr, model.RequestIssued)
+}
+
+// a single denial rejects the request
+func (s *ApprovalService) Deny(id, approverId string) (*model.CertRequest, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cr, _, err := s.pendingRequest(id, approverId)
+	if err != nil {
+		return nil, err
+	}
+
+	cr.Status = model.RequestDenied
+	cr.DeniedBy = approverId
+
+	err = s.store.UpdateRequest(*cr, model.RequestPending)
+	if errors.Is(err, repo.ErrNotExist) {
+		return nil, ErrRequestNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// approver is the token id, requester can't approve or deny own request
+func (s *ApprovalService) pendingRequest(id, approverId string) (*model.CertRequest, *SSHCertCAService, error) {
+	cr, err := s.store.GetRequestById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cr.Status != model.RequestPending || cr.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrRequestNotPending
+	}
+
+	rule, c, err := s.rule(cr.Type)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !rule.CanApprove(approverId) {
+		return nil, nil, ErrNotApprover
+	}
+
+	if cr.RequesterId == approverId {
+		return nil, nil, ErrSelfApproval
+	}
+
+	return cr, c, nil
+}
+
+func (s *ApprovalService) Stop() error {
+	s.tasks.WaitAndStop()
+	return s.store.Close()
+}
+
+func (s *ApprovalService) taskExpirePendingRequests() error {
+	_, err := s.store.ExpirePendingRequests(time.Now())
+	return err
+}
+
+// rebuild the approved signing request from the record
+func approvedSignRequest(cr *model.CertRequest) (*SignRequest, error) {
+	pubkey, err := utils.ParseSSHPublicKey([]byte(cr.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	req := &SignRequest{
+		PublicKey:   pubkey,
+		KeyId:       cr.CertKeyId,
+		Serial:      cr.Serial,
+		Requester:   cr.Requester,
+		RequesterId: cr.RequesterId,
+		ClientIP:    cr.ClientIP,
+		Identity:    cr.Identity,
+		Principals:  strings.Split(cr.Principals, ","),
+		TTL:         time.Duration(cr.TTL) * time.Second,
+		Approved:    true,
+	}
+
+	if cr.Options != "" {
+		req.Options = &ca.CertOptions{}
+		err = json.Unmarshal([]byte(cr.Options), req.Options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/event"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/breakglass"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrMissingJustification = errs.New(errs.CodeInvalidInput, "justification and ticket are required")
+var ErrBreakGlassCooldown = errs.New(errs.CodeCooldown, "break-glass certificate was issued recently, try again later")
+var ErrNotRenewable = errs.New(errs.CodeNotRenewable, "certificate is not renewable")
+
+// key ids of break-glass certificates start with it, for sshd logs
+const breakGlassKeyIdPrefix = "breakglass:"
+
+// max bytes of ticket, requester and justification embedded in key id, full text is kept in the record.
+// the three of them along with prefix, separators, timestamp and a -<n> suffix stay under model.MaxKeyIdLength
+const maxKeyIdField = 128
+
+// default lifetime of break-glass certificates
+const defaultBreakGlassTTL = 15 * time.Minute
+
+// BreakGlassService issues short emergency user certificates for a fixed principal without approval,
+// every issuance is recorded and published as high priority event
+type BreakGlassService struct {
+	ca        *SSHCertCAService
+	store     breakglass.BreakGlassRepo
+	events    *event.Bus
+	principal string
+	ttl       time.Duration
+	cooldown  time.Duration
+	lock      *sync.Mutex
+}
+
+func NewBreakGlassService(dbdriver, dsn string, userca *SSHCertCAService, events *event.Bus, principal string, ttl, cooldown time.Duration) *BreakGlassService {
+	if ttl <= 0 {
+		ttl = defaultBreakGlassTTL
+	}
+
+	return &BreakGlassService{
+		ca:        userca,
+		store:     breakglass.NewBreakGlassRepo(dbdriver, dsn),
+		events:    events,
+		principal: principal,
+		ttl:       ttl,
+		cooldown:  cooldown,
+		lock:      &sync.Mutex{},
+	}
+}
+
+// issue an emergency certificate, justification and ticket are embedded in its key id
+func (s *BreakGlassService) Issue(pubkey ssh.PublicKey, requester, justification, ticket, clientIP string) (c model.Cert, rec *model.BreakGlass, err error) {
+	justification = strings.TrimSpace(justification)
+	ticket = strings.TrimSpace(ticket)
+	if justification == "" || ticket == "" {
+		err = ErrMissingJustification
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.cooldown > 0 {
+		latest, lerr := s.store.GetLatestRecord()
+		if lerr != nil && !errors.Is(lerr, repo.ErrNotExist) {
+			err = lerr
+			return
+		}
+		if latest != nil && time.Since(latest.CreatedAt) < s.cooldown {
+			err = ErrBreakGlassCooldown
+			return
+		}
+	}
+
+	now := time.Now()
+	keyid := fmt.Sprintf("%s%s:%s:%s:%d", breakGlassKeyIdPrefix, keyIdField(ticket, maxKeyIdField), keyIdField(requester, maxKeyIdField),
+		keyIdField(justification, maxKeyIdField), now.UnixNano())
+
+	c, err = s.ca.Issue(&SignRequest{
+		PublicKey:  pubkey,
+		KeyId:      keyid,
+		Principals: []string{s.principal},
+		TTL:        s.ttl,
+		// emergency access must not wait for approvers nor the directory
+		Approved:   true,
+		Granted:    true,
+		BreakGlass: true,
+	})
+	if err != nil {
+		log.Printf("BREAK-GLASS request by %s from %s failed: %v", requester, clientIP, err)
+		return
+	}
+
+	rec = &model.BreakGlass{
+		Id:            uuid.NewString(),
+		KeyId:         c.KeyId,
+		Requester:     requester,
+		Principal:     s.principal,
+		Justification: justification,
+		Ticket:        ticket,
+		ClientIP:      clientIP,
+		CreatedAt:     now,
+		ValidEnd:      c.ValidEnd,
+	}
+
+	err = s.store.CreateRecord(*rec)
+	if err != nil {
+		// never leave an unaudited emergency certificate behind
+		s.ca.Revoke(c.KeyId)
+		return
+	}
+
+	log.Printf("BREAK-GLASS certificate %s for %s issued to %s from %s, ticket %s: %s",
+		c.KeyId, s.principal, requester, clientIP, ticket, justification)
+
+	s.events.Publish(event.TypeBreakGlass, event.PriorityHigh, rec)
+
+	return
+}
+
+func (s *BreakGlassService) Records() ([]*model.BreakGlass, error) {
+	return s.store.GetRecords()
+}
+
+func (s *BreakGlassService) Stop() error {
+	return s.store.Close()
+}
+
+// printable single line text without the key id separator, truncated on rune boundary to max bytes if max > 0
+func keyIdField(s string, max int) string {
+	var b strings.Builder
+	space := false
+
+	for _, r := range s {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) || r == ':' {
+			space = true
+			continue
+		}
+
+		size := utf8.RuneLen(r)
+		if space && b.Len() > 0 {
+			size++
+		}
+		if max > 0 && b.Len()+size > max {
+			break
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		space = false
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
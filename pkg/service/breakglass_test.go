@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+)
+
+func TestKeyIdField(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"INC-1234", 0, "INC-1234"},
+		{" prod  db\tdown:\nnow ", 0, "prod_db_down_now"},
+		{"abcdef", 4, "abcd"},
+		// the separator counts as well
+		{"ab cd", 3, "ab"},
+		{"数据库宕机", 7, "数据"},
+		{"🔥🔥", 5, "🔥"},
+	} {
+		got := keyIdField(tc.in, tc.max)
+		if got != tc.want {
+			t.Fatalf("keyIdField(%q, %d) = %q, want %q", tc.in, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestBreakGlassKeyIdFits(t *testing.T) {
+	long := strings.Repeat("紧急🔥", 200)
+
+	keyid := fmt.Sprintf("%s%s:%s:%s:%d-%d", breakGlassKeyIdPrefix, keyIdField(long, maxKeyIdField), keyIdField(long, maxKeyIdField),
+		keyIdField(long, maxKeyIdField), int64(1<<63-1), 99999)
+
+	if len(keyid) > model.MaxKeyIdLength {
+		t.Fatalf("key id of %d bytes exceeds %d", len(keyid), model.MaxKeyIdLength)
+	}
+	if !utf8.ValidString(keyid) {
+		t.Fatal("key id is cut inside a rune")
+	}
+}
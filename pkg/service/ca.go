@@ -1,22 +1,84 @@
 package service
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/0w0mewo/ssh_cert_ca/internal/model"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/ca"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/event"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/policy"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/cert"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/importedkrl"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo/revocation"
 	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/verify"
+	"github.com/google/uuid"
+	"github.com/stripe/krl"
 	"golang.org/x/crypto/ssh"
 )
 
 type SSHCertCAService struct {
-	certStore  cert.CertRepo
-	kepair     *ca.CAKeyPairs
-	role       model.RoleType
-	revokeTask *utils.ScheduledTaskGroup
-	cachedKRL  []byte
+	certStore cert.CertRepo
+	// why and by whom certificates are revoked
+	revocations revocation.RevocationRepo
+	// KRLs of an external CA, merged into generated ones
+	importedKRLs importedkrl.ImportedKRLRepo
+	kepair       *ca.CAKeyPairs
+	role         model.RoleType
+	revokeTask   *utils.ScheduledTaskGroup
+	cachedKRL    []byte
+	krlVersion   uint64
+	krlLock      *sync.RWMutex
+	// closed and replaced once the KRL is regenerated
+	krlChanged chan struct{}
+
+	hostKeyVerifier verify.HostKeyVerifier
+	policy          *policy.Engine
+	keyIdTemplate   *template.Template
+	// key id allocation and storing the certificate happen together
+	issueLock *sync.Mutex
+	// KRL and certificate lifecycle events are published if it's set
+	events *event.Bus
+	// retired keys of the CA, only used to recognize certificates signed by them
+	previousKeys []ssh.PublicKey
+}
+
+// SignRequest describes a certificate to be issued
+type SignRequest struct {
+	PublicKey ssh.PublicKey
+	// rendered from the key id template of the CA if it's empty, made unique against the store
+	KeyId string
+	// random if it's zero
+	Serial uint64
+	// authenticated requester and its address, for key id templates
+	Requester string
+	ClientIP  string
+	// token id of the requester, the one collecting the certificate if it's held back for approval
+	RequesterId string
+	// requester identity consulted by the policy, the requester if it's empty
+	Identity   string
+	Principals []string
+	TTL        time.Duration
+	Options    *ca.CertOptions
+	// skip approval, e.g. the request has been approved or it's made offline by operator
+	Approved bool
+	// skip identity resolution, principals and options are granted already,
+	// e.g. renewal, break-glass, or operator signing explicit principals
+	Granted bool
+	// emergency certificate, recorded as such and never renewed
+	BreakGlass bool
+	// user certificates get the first requested principal only unless it's set,
+	// e.g. principals derived from OIDC claims or kept on renewal
+	AllPrincipals bool
 }
 
 func NewSSHCertCAService(dbdriver, dsn string, privKeyFile, passparse string, role model.RoleType) (*SSHCertCAService, error) {
@@ -26,10 +88,15 @@ func NewSSHCertCAService(dbdriver, dsn string, privKeyFile, passparse string, ro
 	}
 
 	ret := &SSHCertCAService{
-		certStore:  cert.NewCertRepo(dbdriver, dsn),
-		kepair:     kp,
-		role:       role,
-		revokeTask: utils.NewScheduledTaskGroup("default"),
+		certStore:    cert.NewCertRepo(dbdriver, dsn),
+		revocations:  revocation.NewRevocationRepo(dbdriver, dsn),
+		importedKRLs: importedkrl.NewImportedKRLRepo(dbdriver, dsn),
+		kepair:       kp,
+		role:         role,
+		revokeTask:   utils.NewScheduledTaskGroup("default"),
+		krlLock:      &sync.RWMutex{},
+		krlChanged:   make(chan struct{}),
+		issueLock:    &sync.Mutex{},
 	}
 
 	ret.regenerateRevokedList()
@@ -42,30 +109,384 @@ func NewSSHCertCAService(dbdriver, dsn string, privKeyFile, passparse string, ro
 
 }
 
+// publish KRL and certificate lifecycle events to the bus
+func (s *SSHCertCAService) SetEventBus(bus *event.Bus) {
+	s.events = bus
+}
+
+// keys the CA used to sign with before rotation
+func (s *SSHCertCAService) SetPreviousKeys(keys []ssh.PublicKey) {
+	s.previousKeys = keys
+}
+
+// require hosts to prove possession of the host key before signing host certificates
+func (s *SSHCertCAService) SetHostKeyVerifier(v verify.HostKeyVerifier) {
+	s.hostKeyVerifier = v
+}
+
+// consult the policy engine before signing, e.g. to map identities to principals
+func (s *SSHCertCAService) SetPolicy(p *policy.Engine) {
+	s.policy = p
+}
+
+// key ids of new certificates are rendered from the template, see KeyIdVars for variables
+func (s *SSHCertCAService) SetKeyIdTemplate(text string) error {
+	tmpl, err := ParseKeyIdTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	s.keyIdTemplate = tmpl
+
+	return nil
+}
+
+func (s *SSHCertCAService) Policy() *policy.Engine {
+	return s.policy
+}
+
 // sign and store the new certificate
 func (s *SSHCertCAService) Sign(pubkeyToSign ssh.PublicKey, keyid string, validPrincipals []string, ttl time.Duration) (c model.Cert, err error) {
+	return s.Issue(&SignRequest{
+		PublicKey:  pubkeyToSign,
+		KeyId:      keyid,
+		Principals: validPrincipals,
+		TTL:        ttl,
+	})
+}
+
+// evaluate the request against the policy, then sign and store the new certificate
+func (s *SSHCertCAService) Issue(req *SignRequest) (c model.Cert, err error) {
 	var isHost bool
 
 	if model.CertTypeHost == s.role {
 		isHost = true
 	}
 
-	c, err = s.kepair.Sign(pubkeyToSign, keyid, 0, validPrincipals, ttl, isHost)
+	err = validateOptions(req.Options)
+	if err != nil {
+		return
+	}
+
+	if !isHost && !req.AllPrincipals && len(req.Principals) > 1 {
+		req.Principals = req.Principals[:1]
+	}
+
+	if s.policy != nil {
+		identity := req.Identity
+		if identity == "" {
+			identity = req.Requester
+		}
+
+		preq := &policy.Request{
+			Identity:   identity,
+			Principals: req.Principals,
+			TTL:        req.TTL,
+			Options:    req.Options,
+			Approved:   req.Approved,
+			Granted:    req.Granted,
+		}
+
+		err = s.policy.Evaluate(preq)
+		if err != nil {
+			return
+		}
+
+		req.Principals, req.Options = preq.Principals, preq.Options
+	}
+
+	if isHost && s.hostKeyVerifier != nil {
+		for _, host := range req.Principals {
+			err = s.hostKeyVerifier.VerifyHostKey(host, req.PublicKey)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if req.Serial == 0 {
+		req.Serial = randomSerial()
+	}
+
+	if req.KeyId == "" {
+		req.KeyId, err = s.renderKeyId(req)
+		if err != nil {
+			return
+		}
+	}
+
+	s.issueLock.Lock()
+	defer s.issueLock.Unlock()
+
+	keyid, err := s.uniqueKeyId(req.KeyId)
 	if err != nil {
 		return
 	}
 
+	if len(keyid) > model.MaxKeyIdLength {
+		err = ErrKeyIdTooLong
+		return
+	}
+
+	c, err = s.kepair.Sign(req.PublicKey, keyid, req.Serial, req.Principals, req.TTL, isHost, req.Options)
+	if err != nil {
+		return
+	}
+	c.BreakGlass = req.BreakGlass
+
 	err = s.certStore.CreateCert(c)
+	if err != nil {
+		return
+	}
+
+	s.publish(event.TypeCertIssued, &CertEvent{
+		Role:       model.FormatType(s.role),
+		KeyId:      c.KeyId,
+		Requester:  req.Requester,
+		Principals: req.Principals,
+		ValidEnd:   &c.ValidEnd,
+	})
+
 	return
+}
+
+// critical options known by OpenSSH, certificates with unknown ones are refused by sshd
+var knownCriticalOptions = map[string]bool{
+	"force-command":   true,
+	"source-address":  true,
+	"verify-required": true,
+}
+
+// critical options unknown to sshd would make the certificate useless
+func validateOptions(opts *ca.CertOptions) error {
+	if opts == nil {
+		return nil
+	}
+
+	for name := range opts.CriticalOptions {
+		if !knownCriticalOptions[name] {
+			return fmt.Errorf("%w: %s", ErrUnknownCriticalOption, name)
+		}
+	}
+
+	return nil
+}
+
+// key id from the template, or uuid if the CA has none
+func (s *SSHCertCAService) renderKeyId(req *SignRequest) (string, error) {
+	if s.keyIdTemplate == nil {
+		return uuid.NewString(), nil
+	}
+
+	var b strings.Builder
+	err := s.keyIdTemplate.Execute(&b, &KeyIdVars{
+		Requester:  req.Requester,
+		Identity:   req.Identity,
+		Principals: req.Principals,
+		Role:       model.FormatType(s.role),
+		Serial:     req.Serial,
+		Timestamp:  time.Now(),
+		ClientIP:   req.ClientIP,
+		Random:     randomHex(8),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if b.Len() == 0 {
+		return "", ErrEmptyKeyId
+	}
 
+	return b.String(), nil
+}
+
+// key id is the primary key of the store, taken ones get -<n> appended
+func (s *SSHCertCAService) uniqueKeyId(keyid string) (string, error) {
+	candidate := keyid
+
+	for n := 2; ; n++ {
+		_, err := s.certStore.GetCertById(candidate)
+		if errors.Is(err, repo.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		candidate = keyid + "-" + strconv.Itoa(n)
+	}
+
+}
+
+// issue a new certificate with the same key, principals and lifetime of a still valid certificate of this CA.
+// key id of the new certificate keeps the lineage of the original one
+func (s *SSHCertCAService) Renew(cert *ssh.Certificate) (c model.Cert, err error) {
+	err = s.CheckRenewable(cert)
+	if err != nil {
+		return
+	}
+
+	ttl := time.Duration(cert.ValidBefore-cert.ValidAfter) * time.Second
+
+	return s.Issue(&SignRequest{
+		PublicKey:  cert.Key,
+		KeyId:      RenewedKeyId(cert.KeyId),
+		Principals: cert.ValidPrincipals,
+		TTL:        ttl,
+		// renewal keeps what has been granted to the original certificate
+		Approved:      true,
+		Granted:       true,
+		AllPrincipals: true,
+		Options: &ca.CertOptions{
+			Extensions:      cert.Extensions,
+			CriticalOptions: cert.CriticalOptions,
+		},
+	})
+}
+
+// check the certificate is valid and allowed to be renewed, break-glass certificates never are
+func (s *SSHCertCAService) CheckRenewable(cert *ssh.Certificate) error {
+	stored, err := s.checkCert(cert)
+	if err != nil {
+		return err
+	}
+
+	if stored.BreakGlass {
+		return ErrNotRenewable
+	}
+
+	return nil
+}
+
+// check the certificate is signed by this CA, within validity, not revoked and known by the store
+func (s *SSHCertCAService) CheckCert(cert *ssh.Certificate) error {
+	_, err := s.checkCert(cert)
+	return err
+}
+
+func (s *SSHCertCAService) checkCert(cert *ssh.Certificate) (*model.Cert, error) {
+	certType := uint32(ssh.UserCert)
+	if s.role == model.CertTypeHost {
+		certType = ssh.HostCert
+	}
+
+	if cert.CertType != certType || len(cert.ValidPrincipals) == 0 {
+		return nil, ErrCertRoleMismatch
+	}
+
+	isAuthority := func(auth ssh.PublicKey) bool {
+		return bytes.Equal(auth.Marshal(), s.kepair.PublicKey().Marshal())
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: isAuthority,
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			return isAuthority(auth)
+		},
+		IsRevoked: s.IsRevoked,
+	}
+
+	err := checker.CheckCert(cert.ValidPrincipals[0], cert)
+	if err != nil {
+		return nil, ErrInvalidCert.Wrap(err)
+	}
+
+	stored, err := s.certStore.GetCertById(cert.KeyId)
+	if errors.Is(err, repo.ErrNotExist) {
+		return nil, ErrInvalidCert.Wrap(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.Revoked {
+		return nil, ErrCertRevoked
+	}
+
+	return stored, nil
+}
+
+// check certificate against the present KRL
+func (s *SSHCertCAService) IsRevoked(cert *ssh.Certificate) bool {
+	k, err := krl.ParseKRL(s.GetPresentRevokedList())
+	if err != nil {
+		return true
+	}
+
+	return k.IsRevoked(cert)
 }
 
 func (s *SSHCertCAService) PublicKeyAsAuthKeyStr() string {
 	return s.kepair.PublicKeyAsAuthKeyStr()
 }
 
+func (s *SSHCertCAService) PublicKey() ssh.PublicKey {
+	return s.kepair.PublicKey()
+}
+
+// whether the key is one of the CA, and whether it's the one the CA signs with now
+func (s *SSHCertCAService) IsAuthority(key ssh.PublicKey) (known bool, current bool) {
+	if bytes.Equal(key.Marshal(), s.kepair.PublicKey().Marshal()) {
+		return true, true
+	}
+
+	for _, k := range s.previousKeys {
+		if bytes.Equal(key.Marshal(), k.Marshal()) {
+			return true, false
+		}
+	}
+
+	return false, false
+}
+
+func (s *SSHCertCAService) Role() model.RoleType {
+	return s.role
+}
+
+func (s *SSHCertCAService) GetCert(keyid string) (*model.Cert, error) {
+	return s.certStore.GetCertById(keyid)
+}
+
+// list of certs which are not revoked
+func (s *SSHCertCAService) ListCerts() ([]*model.Cert, error) {
+	return s.certStore.GetCertsByRole(s.role)
+}
+
+// list of certs including revoked ones
+func (s *SSHCertCAService) ListAllCerts() ([]*model.Cert, error) {
+	certs, err := s.certStore.GetCertsByRole(s.role)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.certStore.GetRevokedCertIdsByRole(s.role)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		cert, err := s.certStore.GetCertById(id)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+func (s *SSHCertCAService) ListRevokedCertIds() ([]string, error) {
+	return s.certStore.GetRevokedCertIdsByRole(s.role)
+}
+
 func (s *SSHCertCAService) Revoke(keyid string) error {
-	err := s.certStore.UpdateRevoke(keyid, true)
+	return s.RevokeWithReason(keyid, "", "")
+}
+
+// revoke certificate and keep the reason along with who revoked it
+func (s *SSHCertCAService) RevokeWithReason(keyid, reason, revokedBy string) error {
+	err := s.markRevoked(keyid, reason, revokedBy)
 	if err != nil {
 		return err
 	}
@@ -73,26 +494,120 @@ func (s *SSHCertCAService) Revoke(keyid string) error {
 	return s.regenerateRevokedList()
 }
 
+// revoke certificate in store, the KRL is left to be regenerated
+func (s *SSHCertCAService) markRevoked(keyid, reason, revokedBy string) error {
+	err := s.certStore.UpdateRevoke(s.role, keyid, true)
+	if err != nil {
+		return err
+	}
+
+	err = s.revocations.SaveRevocation(model.Revocation{
+		KeyId:     keyid,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		RevokedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(event.TypeCertRevoked, &CertEvent{Role: model.FormatType(s.role), KeyId: keyid, Reason: reason})
+
+	return nil
+}
+
+// revocation record of the certificate, repo.ErrNotExist if it's not revoked or revoked on expiry
+func (s *SSHCertCAService) GetRevocation(keyid string) (*model.Revocation, error) {
+	return s.revocations.GetRevocationById(keyid)
+}
+
+// revocation records keyed by certificate key id
+func (s *SSHCertCAService) ListRevocations() (map[string]*model.Revocation, error) {
+	revs, err := s.revocations.GetRevocations()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*model.Revocation, len(revs))
+	for _, r := range revs {
+		res[r.KeyId] = r
+	}
+
+	return res, nil
+}
+
 func (s *SSHCertCAService) regenerateRevokedList() (err error) {
 	certs, err := s.certStore.GetRevokedCertIdsByRole(s.role)
 	if err != nil {
 		return
 	}
 
-	s.cachedKRL, err = s.kepair.GenerateRevokedList(certs...)
+	imported, err := s.importedSections()
 	if err != nil {
 		return
 	}
 
+	s.krlLock.Lock()
+	defer s.krlLock.Unlock()
+
+	// unix time based version keeps increasing across restarts
+	version := uint64(time.Now().Unix())
+	if version <= s.krlVersion {
+		version = s.krlVersion + 1
+	}
+
+	krl, err := s.kepair.GenerateRevokedList(version, imported, certs...)
+	if err != nil {
+		return
+	}
+
+	s.cachedKRL = krl
+	s.krlVersion = version
+
+	close(s.krlChanged)
+	s.krlChanged = make(chan struct{})
+
+	s.publish(event.TypeKRL, &KRLEvent{Role: model.FormatType(s.role), Version: version})
+
 	return
 }
 
+func (s *SSHCertCAService) publish(typ string, data any) {
+	if s.events != nil {
+		s.events.Publish(typ, event.PriorityNormal, data)
+	}
+}
+
+func (s *SSHCertCAService) GetPresentRevokedList() []byte {
+	s.krlLock.RLock()
+	defer s.krlLock.RUnlock()
+
+	return s.cachedKRL
+}
+
+func (s *SSHCertCAService) GetPresentRevokedListWithVersion() ([]byte, uint64) {
+	s.krlLock.RLock()
+	defer s.krlLock.RUnlock()
+
+	return s.cachedKRL, s.krlVersion
+}
+
+// channel closed on next change of the KRL, watchers get a new one after each change
+func (s *SSHCertCAService) KRLChanged() <-chan struct{} {
+	s.krlLock.RLock()
+	defer s.krlLock.RUnlock()
+
+	return s.krlChanged
+}
+
 func (s *SSHCertCAService) GetPresentRevokedListBase64() string {
-	return base64.StdEncoding.EncodeToString(s.cachedKRL)
+	return base64.StdEncoding.EncodeToString(s.GetPresentRevokedList())
 }
 
 func (s *SSHCertCAService) Stop() error {
 	s.revokeTask.WaitAndStop()
+	s.revocations.Close()
+	s.importedKRLs.Close()
 	return s.certStore.Close()
 }
 
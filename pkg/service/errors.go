@@ -0,0 +1,14 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+)
+
+var ErrCertRevoked = errs.New(errs.CodeCertRevoked, "certificate is revoked")
+var ErrCertRoleMismatch = errs.New(errs.CodeInvalidCert, "certificate is not issued by this CA role")
+var ErrEmptyKeyId = errors.New("key id template rendered empty key id")
+var ErrKeyIdTooLong = errs.New(errs.CodeInvalidInput, "key id is too long")
+var ErrInvalidCert = errs.New(errs.CodeInvalidCert, "certificate is not valid")
+var ErrUnknownCriticalOption = errs.New(errs.CodeInvalidInput, "unknown critical option")
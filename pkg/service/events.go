@@ -0,0 +1,20 @@
+package service
+
+import "time"
+
+// data of event.TypeKRL events
+type KRLEvent struct {
+	Role    string `json:"role"`
+	Version uint64 `json:"version"`
+}
+
+// data of certificate lifecycle events
+type CertEvent struct {
+	Role       string     `json:"role"`
+	KeyId      string     `json:"id"`
+	Requester  string     `json:"requester,omitempty"`
+	Principals []string   `json:"principals,omitempty"`
+	ValidEnd   *time.Time `json:"valid_end,omitempty"`
+	// revocation only
+	Reason string `json:"reason,omitempty"`
+}
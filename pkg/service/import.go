@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/internal/model"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/repo"
+	"github.com/0w0mewo/ssh_cert_ca/pkg/utils"
+	"github.com/stripe/krl"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrNotSignedByCA = errs.New(errs.CodeInvalidCert, "certificate is not signed by the CA key")
+var ErrMissingKeyId = errs.New(errs.CodeInvalidCert, "certificate has no key id")
+var ErrKeyIdTaken = errs.New(errs.CodeConflict, "key id is taken by another certificate")
+var ErrInvalidKRL = errs.New(errs.CodeInvalidInput, "invalid KRL")
+var ErrKRLNotSignedByCA = errs.New(errs.CodeInvalidInput, "KRL is not signed by the CA key")
+var ErrKRLOfAnotherCA = errs.New(errs.CodeInvalidInput, "KRL revokes certificates of another CA")
+var ErrKRLAlreadySigned = errs.New(errs.CodeInvalidInput, "KRL is already signed")
+
+// revocations of imported certificates and KRLs are recorded by
+const importer = "import"
+
+// end of validity stored for certificates valid forever
+var foreverEnd = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// ImportCert stores a certificate signed by the CA key elsewhere, e.g. with ssh-keygen -s.
+// It's stored as revoked if an imported KRL revokes it. Importing a stored certificate again is a no-op
+func (s *SSHCertCAService) ImportCert(cert *ssh.Certificate) (c model.Cert, err error) {
+	certType := uint32(ssh.UserCert)
+	if s.role == model.CertTypeHost {
+		certType = ssh.HostCert
+	}
+
+	if cert.CertType != certType {
+		return c, ErrCertRoleMismatch
+	}
+
+	// only the present key, KRLs generated by the CA revoke certificates of it
+	if _, current := s.IsAuthority(cert.SignatureKey); !current {
+		return c, ErrNotSignedByCA
+	}
+
+	err = utils.VerifySSHCertSignature(cert)
+	if err != nil {
+		return c, ErrInvalidCert.Wrap(err)
+	}
+
+	if cert.KeyId == "" {
+		return c, ErrMissingKeyId
+	}
+
+	c = model.Cert{
+		KeyId:      cert.KeyId,
+		Type:       s.role,
+		ValidStart: time.Unix(int64(cert.ValidAfter), 0),
+		ValidEnd:   foreverEnd,
+		Content:    fmt.Sprintf("%s %s", cert.Type(), base64.StdEncoding.EncodeToString(cert.Marshal())),
+		Revoked:    s.IsRevoked(cert),
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		c.ValidEnd = time.Unix(int64(cert.ValidBefore), 0)
+	}
+
+	s.issueLock.Lock()
+	defer s.issueLock.Unlock()
+
+	stored, err := s.certStore.GetCertById(c.KeyId)
+	switch {
+	case err == nil:
+		if sc, err := utils.ParseSSHCert([]byte(stored.Content)); err == nil && bytes.Equal(sc.Marshal(), cert.Marshal()) {
+			return *stored, nil
+		}
+		return c, ErrKeyIdTaken
+	case !errors.Is(err, repo.ErrNotExist):
+		return c, err
+	}
+
+	err = s.certStore.CreateCert(c)
+	if err != nil {
+		return c, err
+	}
+
+	if c.Revoked {
+		err = s.revocations.SaveRevocation(model.Revocation{
+			KeyId:     c.KeyId,
+			Reason:    "revoked by imported KRL",
+			RevokedBy: importer,
+			RevokedAt: time.Now(),
+		})
+		if err != nil {
+			return c, err
+		}
+
+		err = s.regenerateRevokedList()
+	}
+
+	return c, err
+}
+
+// ImportKRL merges a KRL signed by the CA key elsewhere, e.g. generated by ssh-keygen -k -s, into every KRL
+// the CA generates and revokes stored certificates it revokes. Returns key ids of the newly revoked certificates
+func (s *SSHCertCAService) ImportKRL(raw []byte) (rec *model.ImportedKRL, revoked []string, err error) {
+	k, err := krl.ParseKRL(raw)
+	if err != nil {
+		return nil, nil, ErrInvalidKRL.Wrap(err)
+	}
+
+	err = s.checkKRL(k)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// signatures differ every time a KRL is signed, so it's known by its contents
+	contents, err := k.Marshal(rand.Reader)
+	if err != nil {
+		return nil, nil, ErrInvalidKRL.Wrap(err)
+	}
+
+	sum := sha256.Sum256(contents)
+	rec = &model.ImportedKRL{
+		Id:         hex.EncodeToString(sum[:]),
+		Type:       s.role,
+		Content:    raw,
+		ImportedAt: time.Now(),
+	}
+
+	err = s.importedKRLs.SaveKRL(*rec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certs, err := s.certStore.GetCertsByRole(s.role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked = make([]string, 0)
+	for _, c := range certs {
+		sc, err := utils.ParseSSHCert([]byte(c.Content))
+		if err != nil || !k.IsRevoked(sc) {
+			continue
+		}
+
+		err = s.markRevoked(c.KeyId, "revoked by imported KRL "+rec.Id[:12], importer)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		revoked = append(revoked, c.KeyId)
+	}
+
+	return rec, revoked, s.regenerateRevokedList()
+}
+
+// sign an unsigned KRL with the present CA key so it can be imported, for operators vouching for
+// KRLs made by ssh-keygen -k. signed ones are left to ImportKRL to be checked as they are
+func (s *SSHCertCAService) SignKRL(raw []byte) ([]byte, error) {
+	k, err := krl.ParseKRL(raw)
+	if err != nil {
+		return nil, ErrInvalidKRL.Wrap(err)
+	}
+
+	if len(k.SigningKeys) > 0 {
+		return nil, ErrKRLAlreadySigned
+	}
+
+	return s.kepair.SignKRL(k)
+}
+
+// signatures are verified by ParseKRL, every signer must be a present or previous key of the CA,
+// and certificates it revokes must be of the CA as well
+func (s *SSHCertCAService) checkKRL(k *krl.KRL) error {
+	if len(k.SigningKeys) == 0 {
+		return ErrKRLNotSignedByCA
+	}
+
+	for _, key := range k.SigningKeys {
+		if known, _ := s.IsAuthority(key); !known {
+			return ErrKRLNotSignedByCA
+		}
+	}
+
+	for _, section := range k.Sections {
+		cs, ok := section.(*krl.KRLCertificateSection)
+		if !ok {
+			continue
+		}
+
+		// no CA means certificates of any CA
+		if cs.CA == nil {
+			return ErrKRLOfAnotherCA
+		}
+
+		if known, _ := s.IsAuthority(cs.CA); !known {
+			return ErrKRLOfAnotherCA
+		}
+	}
+
+	return nil
+}
+
+// sections of imported KRLs
+func (s *SSHCertCAService) importedSections() ([]krl.KRLSection, error) {
+	recs, err := s.importedKRLs.GetKRLsByRole(s.role)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make([]krl.KRLSection, 0)
+	for _, rec := range recs {
+		k, err := krl.ParseKRL(rec.Content)
+		if err != nil {
+			return nil, ErrInvalidKRL.Wrap(err)
+		}
+
+		sections = append(sections, k.Sections...)
+	}
+
+	return sections, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// separator between the lineage and the renewal generation of a key id
+const lineageSep = "+"
+
+// the key id the certificate originally issued with
+func KeyIdLineage(keyid string) string {
+	lineage, _, _ := strings.Cut(keyid, lineageSep)
+	return lineage
+}
+
+// key id of the renewed certificate, e.g. <lineage>+<base36 unix nano time>
+func RenewedKeyId(keyid string) string {
+	return KeyIdLineage(keyid) + lineageSep + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// variables of key id templates
+type KeyIdVars struct {
+	// authenticated requester, e.g. token name or login identity
+	Requester string
+	// identity resolved by the policy, may be empty
+	Identity   string
+	Principals []string
+	Role       string
+	Serial     uint64
+	Timestamp  time.Time
+	ClientIP   string
+	// 8 random hex digits
+	Random string
+}
+
+var keyIdFuncs = template.FuncMap{
+	"join": strings.Join,
+	"date": func(layout string, t time.Time) string {
+		return t.UTC().Format(layout)
+	},
+}
+
+// parse key id template, e.g. {{.Requester}}@{{.ClientIP}}:{{join .Principals ","}}:{{.Timestamp.Unix}}:{{.Random}}
+func ParseKeyIdTemplate(text string) (*template.Template, error) {
+	return template.New("keyid").Option("missingkey=error").Funcs(keyIdFuncs).Parse(text)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, (n+1)/2)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)[:n]
+}
+
+// random non zero serial, it's not sequential so there is nothing to keep in sync across instances
+func randomSerial() uint64 {
+	var b [8]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return binary.BigEndian.Uint64(b[:])>>1 | 1
+}
@@ -1,13 +1,26 @@
 package utils
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
 	"golang.org/x/crypto/ssh"
 )
 
+var ErrNotSSHCert = errs.New(errs.CodeInvalidKey, "not a ssh certificate")
+
+var ErrInvalidKey = errs.New(errs.CodeInvalidKey, "invalid ssh public key")
+
 func ParseSSHPublicKey(in []byte) (ssh.PublicKey, error) {
 	key, _, _, _, err := ssh.ParseAuthorizedKey(in)
+	if err != nil {
+		return nil, ErrInvalidKey.Wrap(err)
+	}
 
-	return key, err
+	return key, nil
 
 }
 
@@ -16,3 +29,87 @@ func IsSSHPublicKey(in []byte) error {
 
 	return err
 }
+
+func ParseSSHCert(in []byte) (*ssh.Certificate, error) {
+	key, err := ParseSSHPublicKey(in)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, ErrNotSSHCert
+	}
+
+	return cert, nil
+}
+
+// check the certificate is signed by its signature key
+func VerifySSHCertSignature(cert *ssh.Certificate) error {
+	// signed data is the certificate without the signature, see PROTOCOL.certkeys
+	c := *cert
+	c.Signature = nil
+	out := c.Marshal()
+
+	return cert.SignatureKey.Verify(out[:len(out)-4], cert.Signature)
+}
+
+// magic of OpenSSH KRL files, see PROTOCOL.krl
+const krlMagic = "SSHKRL\n\x00"
+
+// whether the content is a binary KRL rather than text
+func IsKRL(in []byte) bool {
+	return strings.HasPrefix(string(in), krlMagic)
+}
+
+func formatCertTime(t uint64) string {
+	if t == ssh.CertTimeInfinity {
+		return "forever"
+	}
+
+	return time.Unix(int64(t), 0).Format(time.RFC3339)
+}
+
+// human readable description of a certificate, similar to ssh-keygen -L
+func FormatSSHCert(cert *ssh.Certificate) string {
+	var sb strings.Builder
+
+	certType := "user"
+	if cert.CertType == ssh.HostCert {
+		certType = "host"
+	}
+
+	fmt.Fprintf(&sb, "Type: %s %s certificate\n", cert.Type(), certType)
+	fmt.Fprintf(&sb, "Public key: %s %s\n", cert.Key.Type(), ssh.FingerprintSHA256(cert.Key))
+	fmt.Fprintf(&sb, "Signing CA: %s %s\n", cert.SignatureKey.Type(), ssh.FingerprintSHA256(cert.SignatureKey))
+	fmt.Fprintf(&sb, "Key ID: %q\n", cert.KeyId)
+	fmt.Fprintf(&sb, "Serial: %d\n", cert.Serial)
+	fmt.Fprintf(&sb, "Valid: from %s to %s\n", formatCertTime(cert.ValidAfter), formatCertTime(cert.ValidBefore))
+
+	fmt.Fprintf(&sb, "Principals:\n")
+	for _, p := range cert.ValidPrincipals {
+		fmt.Fprintf(&sb, "        %s\n", p)
+	}
+
+	fmt.Fprintf(&sb, "Critical Options:\n")
+	for _, k := range sortedKeys(cert.CriticalOptions) {
+		fmt.Fprintf(&sb, "        %s %s\n", k, cert.CriticalOptions[k])
+	}
+
+	fmt.Fprintf(&sb, "Extensions:\n")
+	for _, k := range sortedKeys(cert.Extensions) {
+		fmt.Fprintf(&sb, "        %s\n", k)
+	}
+
+	return sb.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
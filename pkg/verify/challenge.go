@@ -0,0 +1,130 @@
+package verify
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrChallengeNotFound = errs.New(errs.CodeChallengeNotFound, "challenge not found or expired")
+var ErrChallengeKeyMismatch = errs.New(errs.CodeInvalidSignature, "challenge was issued for another key")
+var ErrBadSignature = errs.New(errs.CodeInvalidSignature, "bad challenge signature")
+
+// prefix of the signed message, so the signature can't be replayed elsewhere
+const ChallengeNamespace = "ssh-cert-ca-challenge@v1:"
+
+type Challenge struct {
+	Id          string    `json:"id"`
+	Nonce       []byte    `json:"nonce"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	fingerprint string
+}
+
+// the message to be signed by the private key
+func ChallengeMessage(nonce []byte) []byte {
+	return append([]byte(ChallengeNamespace), nonce...)
+}
+
+// sign challenge nonce and encode signature for transport
+func SignChallenge(signer ssh.Signer, nonce []byte) (string, error) {
+	sig, err := signer.Sign(rand.Reader, ChallengeMessage(nonce))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(ssh.Marshal(sig)), nil
+}
+
+func ParseSignature(encoded string) (*ssh.Signature, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &ssh.Signature{}
+	err = ssh.Unmarshal(raw, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// ChallengeStore keeps single-use nonces in memory until they are used or expired
+type ChallengeStore struct {
+	ttl   time.Duration
+	store map[string]*Challenge
+	lock  *sync.Mutex
+}
+
+func NewChallengeStore(ttl time.Duration) *ChallengeStore {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	return &ChallengeStore{
+		ttl:   ttl,
+		store: make(map[string]*Challenge),
+		lock:  &sync.Mutex{},
+	}
+}
+
+// issue a nonce for the public key
+func (cs *ChallengeStore) Issue(key ssh.PublicKey) (*Challenge, error) {
+	nonce := make([]byte, 32)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &Challenge{
+		Id:          uuid.NewString(),
+		Nonce:       nonce,
+		ExpiresAt:   time.Now().Add(cs.ttl),
+		fingerprint: ssh.FingerprintSHA256(key),
+	}
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	cs.purgeExpired()
+	cs.store[ch.Id] = ch
+
+	return ch, nil
+}
+
+// check the signature of challenge nonce by the key, the challenge is consumed whatever the result is
+func (cs *ChallengeStore) Verify(id string, key ssh.PublicKey, sig *ssh.Signature) error {
+	cs.lock.Lock()
+	ch, exist := cs.store[id]
+	delete(cs.store, id)
+	cs.lock.Unlock()
+
+	if !exist || time.Now().After(ch.ExpiresAt) {
+		return ErrChallengeNotFound
+	}
+
+	if ch.fingerprint != ssh.FingerprintSHA256(key) {
+		return ErrChallengeKeyMismatch
+	}
+
+	if sig == nil || key.Verify(ChallengeMessage(ch.Nonce), sig) != nil {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+func (cs *ChallengeStore) purgeExpired() {
+	now := time.Now()
+	for id, ch := range cs.store {
+		if now.After(ch.ExpiresAt) {
+			delete(cs.store, id)
+		}
+	}
+}
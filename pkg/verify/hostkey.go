@@ -0,0 +1,95 @@
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/0w0mewo/ssh_cert_ca/pkg/errs"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrHostKeyMismatch = errs.New(errs.CodeHostKeyMismatch, "host does not present the submitted host key")
+
+// HostKeyVerifier confirms that a host is in possession of the host key
+type HostKeyVerifier interface {
+	VerifyHostKey(host string, key ssh.PublicKey) error
+}
+
+// SSHHandshakeVerifier connects to the host over ssh and compares the host key presented in handshake.
+// the handshake is aborted once the host key is received, no authentication is attempted
+type SSHHandshakeVerifier struct {
+	Port    int
+	Timeout time.Duration
+}
+
+func NewSSHHandshakeVerifier(port int, timeout time.Duration) *SSHHandshakeVerifier {
+	if port <= 0 {
+		port = 22
+	}
+
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &SSHHandshakeVerifier{
+		Port:    port,
+		Timeout: timeout,
+	}
+}
+
+var errAbortHandshake = errors.New("host key received")
+
+// host can be either a hostname or host:port
+func (v *SSHHandshakeVerifier) VerifyHostKey(host string, key ssh.PublicKey) error {
+	addr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		addr = net.JoinHostPort(host, strconv.Itoa(v.Port))
+	}
+
+	var presented ssh.PublicKey
+	cfg := &ssh.ClientConfig{
+		User: "ssh-cert-ca",
+		HostKeyCallback: func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			presented = k
+			return errAbortHandshake
+		},
+		// ask for the host key of the same type only
+		HostKeyAlgorithms: hostKeyAlgorithms(key),
+		Timeout:           v.Timeout,
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, v.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(v.Timeout))
+
+	c, _, _, err := ssh.NewClientConn(conn, addr, cfg)
+	if err == nil {
+		c.Close()
+	}
+
+	if presented == nil {
+		return fmt.Errorf("%s: %w: %v", addr, ErrHostKeyMismatch, err)
+	}
+
+	if !bytes.Equal(presented.Marshal(), key.Marshal()) {
+		return fmt.Errorf("%s: %w", addr, ErrHostKeyMismatch)
+	}
+
+	return nil
+}
+
+func hostKeyAlgorithms(key ssh.PublicKey) []string {
+	if key.Type() == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+
+	return []string{key.Type()}
+}
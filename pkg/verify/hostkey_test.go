@@ -0,0 +1,154 @@
+package verify
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// in-process ssh server presenting the host keys of signers
+func startSSHServer(t *testing.T, signers ...ssh.Signer) string {
+	t.Helper()
+
+	cfg := &ssh.ServerConfig{NoClientAuth: true}
+	for _, s := range signers {
+		cfg.AddHostKey(s)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, cfg)
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func newEd25519Signer(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func newECDSASigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestVerifyHostKeyPresented(t *testing.T) {
+	host := newEd25519Signer(t)
+	addr := startSSHServer(t, host)
+
+	v := NewSSHHandshakeVerifier(0, 5*time.Second)
+	err := v.VerifyHostKey(addr, host.PublicKey())
+	if err != nil {
+		t.Fatalf("expected host key to be verified, got %v", err)
+	}
+}
+
+func TestVerifyHostKeyMismatch(t *testing.T) {
+	addr := startSSHServer(t, newEd25519Signer(t))
+
+	v := NewSSHHandshakeVerifier(0, 5*time.Second)
+	err := v.VerifyHostKey(addr, newEd25519Signer(t).PublicKey())
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("expected ErrHostKeyMismatch, got %v", err)
+	}
+}
+
+func TestVerifyHostKeyOfSameType(t *testing.T) {
+	ed, ec := newEd25519Signer(t), newECDSASigner(t)
+	addr := startSSHServer(t, ed, ec)
+
+	v := NewSSHHandshakeVerifier(0, 5*time.Second)
+	for _, key := range []ssh.PublicKey{ed.PublicKey(), ec.PublicKey()} {
+		err := v.VerifyHostKey(addr, key)
+		if err != nil {
+			t.Fatalf("expected %s host key to be verified, got %v", key.Type(), err)
+		}
+	}
+}
+
+func TestVerifyHostKeyTypeNotOffered(t *testing.T) {
+	addr := startSSHServer(t, newEd25519Signer(t))
+
+	v := NewSSHHandshakeVerifier(0, 5*time.Second)
+	err := v.VerifyHostKey(addr, newECDSASigner(t).PublicKey())
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("expected ErrHostKeyMismatch, got %v", err)
+	}
+}
+
+func TestVerifyHostKeyDefaultPort(t *testing.T) {
+	host := newEd25519Signer(t)
+	addr := startSSHServer(t, host)
+
+	hostname, port, _ := net.SplitHostPort(addr)
+	p, err := net.LookupPort("tcp", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewSSHHandshakeVerifier(p, 5*time.Second)
+	err = v.VerifyHostKey(hostname, host.PublicKey())
+	if err != nil {
+		t.Fatalf("expected host key to be verified on configured port, got %v", err)
+	}
+}
+
+func TestVerifyHostKeyUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	v := NewSSHHandshakeVerifier(0, time.Second)
+	err = v.VerifyHostKey(addr, newEd25519Signer(t).PublicKey())
+	if err == nil {
+		t.Fatal("expected error connecting to a closed port")
+	}
+}